@@ -0,0 +1,342 @@
+package archive
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"fishpi/logger"
+)
+
+var (
+	bucketRecords = []byte("records") // key -> Record
+	bucketTime    = []byte("time")    // 时间(8字节) + key -> 空 按时间遍历和清理过期记录
+	bucketIndex   = []byte("index")   // 二元组 + 0 + key -> 空 全文索引 见 ngrams()
+)
+
+// 存档的消息类型 与聊天室ws消息的type一致
+const (
+	TypeMsg             = "msg"
+	TypeRevoke          = "revoke"
+	TypeRedPacketStatus = "redPacketStatus"
+	TypeBarrage         = "barrager"
+	TypeDiscussChanged  = "discussChanged"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+var ErrNotFound = errors.New("记录不存在")
+
+// Record 一条存档记录
+type Record struct {
+	Key       string          `json:"key"` // 聊天消息为oId 其余类型见 key()
+	OId       string          `json:"oId,omitempty"`
+	Type      string          `json:"type"`
+	Time      time.Time       `json:"time"` // 聊天消息为发送时间 其余为接收时间
+	UserName  string          `json:"userName,omitempty"`
+	Nickname  string          `json:"userNickname,omitempty"`
+	Client    string          `json:"client,omitempty"`
+	Content   string          `json:"content,omitempty"` // 聊天消息优先使用Markdown
+	Revoked   bool            `json:"revoked,omitempty"`
+	RevokedAt time.Time       `json:"revokedAt,omitempty"`
+	Raw       json.RawMessage `json:"raw"` // 原始消息
+}
+
+// wsMsg 聊天室ws消息中需要存档的字段
+type wsMsg struct {
+	Type           string `json:"type"`
+	OId            string `json:"oId"`
+	Time           string `json:"time"`
+	UserName       string `json:"userName"`
+	UserNickname   string `json:"userNickname"`
+	Client         string `json:"client"`
+	Content        string `json:"content"`
+	Md             string `json:"md"`
+	NewDiscuss     string `json:"newDiscuss"`
+	WhoGive        string `json:"whoGive"`
+	WhoGot         string `json:"whoGot"`
+	BarrageContent string `json:"barragerContent"`
+}
+
+// Archive 聊天记录存档 保存在本地的bbolt文件中
+type Archive struct {
+	db        *bolt.DB
+	retention time.Duration // 0为永久保留
+	now       func() time.Time
+
+	logger logger.Logger
+}
+
+// Open 打开存档文件 文件被其他进程占用时返回错误
+func Open(path string, retention time.Duration, logger logger.Logger) (*Archive, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开存档文件 %s 失败：%w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		indexed := tx.Bucket(bucketIndex) != nil
+		for _, name := range [][]byte{bucketRecords, bucketTime, bucketIndex} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if !indexed {
+			return reindex(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Archive{
+		db:        db,
+		retention: retention,
+		now:       time.Now,
+		logger:    logger.Named("archive"),
+	}, nil
+}
+
+func (a *Archive) Name() string {
+	return "archive"
+}
+
+// Run 定时清理过期记录 ctx结束时关闭存档文件
+func (a *Archive) Run(ctx context.Context) error {
+	defer a.Close()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		a.prune()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (a *Archive) Close() error {
+	return a.db.Close()
+}
+
+// HandleMsg 存档聊天室ws消息 在线人数等状态消息不存档
+func (a *Archive) HandleMsg(bytes []byte) {
+	var m wsMsg
+	if err := json.Unmarshal(bytes, &m); err != nil {
+		a.logger.Warn("parse message failed", "err", err, "body", string(bytes))
+		return
+	}
+
+	var err error
+	switch m.Type {
+	case TypeMsg, TypeRedPacketStatus, TypeBarrage, TypeDiscussChanged:
+		err = a.Save(a.record(&m, bytes))
+	case TypeRevoke:
+		err = a.revoke(m.OId, bytes)
+	default:
+		return
+	}
+	if err != nil {
+		a.logger.Error("save record failed", "type", m.Type, "oId", m.OId, "err", err)
+	}
+}
+
+func (a *Archive) record(m *wsMsg, raw []byte) *Record {
+	r := &Record{
+		OId:      m.OId,
+		Type:     m.Type,
+		Time:     a.now(),
+		UserName: m.UserName,
+		Nickname: m.UserNickname,
+		Client:   m.Client,
+		Raw:      append(json.RawMessage(nil), raw...),
+	}
+	switch m.Type {
+	case TypeMsg:
+		if t, err := time.ParseInLocation(timeLayout, m.Time, time.Local); err == nil {
+			r.Time = t
+		}
+		r.Content = m.Md
+		if r.Content == "" {
+			r.Content = m.Content
+		}
+	case TypeRedPacketStatus:
+		r.UserName = m.WhoGot
+		r.Content = fmt.Sprintf("%s领取了%s发的红包", m.WhoGot, m.WhoGive)
+	case TypeBarrage:
+		r.Content = m.BarrageContent
+	case TypeDiscussChanged:
+		r.Content = m.NewDiscuss
+	}
+	r.Key = key(r)
+	return r
+}
+
+// key 聊天消息使用oId 红包领取记录使用 {type}/{oId}/{领取人} 其余使用 {type}/{接收时间}
+func key(r *Record) string {
+	switch r.Type {
+	case TypeMsg:
+		return r.OId
+	case TypeRedPacketStatus:
+		return fmt.Sprintf("%s/%s/%s", r.Type, r.OId, r.UserName)
+	default:
+		return fmt.Sprintf("%s/%d", r.Type, r.Time.UnixNano())
+	}
+}
+
+// Save 保存记录 相同key的记录会被覆盖
+func (a *Archive) Save(r *Record) error {
+	if r.Key == "" {
+		r.Key = key(r)
+	}
+	return a.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, r)
+	})
+}
+
+// revoke 标记原消息已撤回 保留原消息内容 原消息不存在时只记录撤回
+func (a *Archive) revoke(oId string, raw []byte) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		r, err := get(tx, oId)
+		if errors.Is(err, ErrNotFound) {
+			r = &Record{Key: oId, OId: oId, Type: TypeRevoke, Time: a.now(), Raw: append(json.RawMessage(nil), raw...)}
+		} else if err != nil {
+			return err
+		}
+		r.Revoked = true
+		r.RevokedAt = a.now()
+		return put(tx, r)
+	})
+}
+
+// Get 按key读取记录 聊天消息的key为oId
+func (a *Archive) Get(key string) (*Record, error) {
+	var r *Record
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		r, err = get(tx, key)
+		return err
+	})
+	return r, err
+}
+
+// Range 按时间顺序遍历[from, to)之间的记录 零值表示不限制 fn返回false时停止
+func (a *Archive) Range(from, to time.Time, fn func(r *Record) bool) error {
+	return a.db.View(func(tx *bolt.Tx) error {
+		records := tx.Bucket(bucketRecords)
+		c := tx.Bucket(bucketTime).Cursor()
+		k, _ := c.First()
+		if !from.IsZero() {
+			k, _ = c.Seek(timeKey(from, ""))
+		}
+		for ; k != nil; k, _ = c.Next() {
+			if !to.IsZero() && !parseTime(k).Before(to) {
+				return nil
+			}
+			var r Record
+			if err := json.Unmarshal(records.Get(k[8:]), &r); err != nil {
+				return err
+			}
+			if !fn(&r) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// Prune 删除before之前的记录
+func (a *Archive) Prune(before time.Time) (int, error) {
+	n := 0
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(bucketRecords)
+		c := tx.Bucket(bucketTime).Cursor()
+		for k, _ := c.First(); k != nil && parseTime(k).Before(before); k, _ = c.First() {
+			if r, err := get(tx, string(k[8:])); err == nil {
+				if err = unindex(tx, r); err != nil {
+					return err
+				}
+			}
+			if err := records.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (a *Archive) prune() {
+	if a.retention <= 0 {
+		return
+	}
+	n, err := a.Prune(a.now().Add(-a.retention))
+	if err != nil {
+		a.logger.Error("prune records failed", "err", err)
+		return
+	}
+	if n > 0 {
+		a.logger.Info("已清理过期记录", "count", n)
+	}
+}
+
+func get(tx *bolt.Tx, key string) (*Record, error) {
+	body := tx.Bucket(bucketRecords).Get([]byte(key))
+	if body == nil {
+		return nil, ErrNotFound
+	}
+	r := new(Record)
+	if err := json.Unmarshal(body, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func put(tx *bolt.Tx, r *Record) error {
+	records := tx.Bucket(bucketRecords)
+	times := tx.Bucket(bucketTime)
+
+	// 覆盖时移除旧的时间索引和全文索引
+	if old, err := get(tx, r.Key); err == nil {
+		if err = times.Delete(timeKey(old.Time, old.Key)); err != nil {
+			return err
+		}
+		if err = unindex(tx, old); err != nil {
+			return err
+		}
+	}
+
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err = records.Put([]byte(r.Key), body); err != nil {
+		return err
+	}
+	if err = times.Put(timeKey(r.Time, r.Key), nil); err != nil {
+		return err
+	}
+	return index(tx, r)
+}
+
+func timeKey(t time.Time, key string) []byte {
+	k := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return append(k, key...)
+}
+
+func parseTime(k []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k[:8])))
+}
@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fishpi/logger"
+)
+
+func TestArchive(t *testing.T) {
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	a, err := Open(filepath.Join(t.TempDir(), "archive.db"), 0, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	a.now = func() time.Time { return now }
+
+	a.HandleMsg([]byte(`{"type":"online","onlineChatCnt":10}`))
+	a.HandleMsg([]byte(`{"type":"msg","oId":"1001","time":"2024-05-01 11:00:00","userName":"alice","userNickname":"A","content":"<p>hi</p>","md":"hi"}`))
+	a.HandleMsg([]byte(`{"type":"msg","oId":"1002","time":"2024-05-01 11:30:00","userName":"bob","content":"<p>yo</p>"}`))
+	a.HandleMsg([]byte(`{"type":"redPacketStatus","oId":"1002","whoGive":"bob","whoGot":"alice","got":1,"count":2}`))
+	a.HandleMsg([]byte(`{"type":"revoke","oId":"1001"}`))
+
+	r, err := a.Get("1001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Content != "hi" || r.UserName != "alice" || !r.Revoked {
+		t.Fatalf("撤回后应当保留原消息：%+v", r)
+	}
+	if _, err = a.Get("redPacketStatus/1002/alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	err = a.Range(time.Time{}, time.Time{}, func(r *Record) bool {
+		keys = append(keys, r.Key)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 || keys[0] != "1001" || keys[1] != "1002" {
+		t.Fatalf("应当按时间排序且不存档在线人数：%v", keys)
+	}
+
+	n, err := a.Prune(now.Add(-time.Minute))
+	if err != nil || n != 2 {
+		t.Fatalf("应当清理两条聊天消息：%d %v", n, err)
+	}
+	if _, err = a.Get("1001"); err != ErrNotFound {
+		t.Fatalf("清理后不应当存在：%v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	path := filepath.Join(t.TempDir(), "archive.db")
+	a, err := Open(path, 0, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.HandleMsg([]byte(`{"type":"msg","oId":"1","time":"2024-05-01 09:00:00","userName":"alice","md":"今天摸鱼了吗"}`))
+	a.HandleMsg([]byte(`{"type":"msg","oId":"2","time":"2024-05-01 10:00:00","userName":"bob","md":"摸鱼 is Fun"}`))
+	a.HandleMsg([]byte(`{"type":"msg","oId":"3","time":"2024-05-02 10:00:00","userName":"alice","md":"鱼摸了一天"}`))
+	a.HandleMsg([]byte(`{"type":"revoke","oId":"2"}`))
+	if err = a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开后索引仍然可用
+	if a, err = Open(path, 0, l); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	search := func(args string) []string {
+		t.Helper()
+		q, err := ParseQuery(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := a.Search(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var oIds []string
+		for _, r := range records {
+			oIds = append(oIds, r.OId)
+		}
+		return oIds
+	}
+
+	cases := map[string]string{
+		"摸鱼":                     "2,1",
+		"摸鱼 user:ALICE":          "1",
+		"fun":                    "2",
+		"鱼":                      "3,2,1",
+		"摸鱼 type:revoke":         "2",
+		"鱼 from:2024-05-02":      "3",
+		"鱼 to:2024-05-01":        "2,1",
+		"鱼 limit:1":              "3",
+		"摸鱼 to:2024-05-01T09:30": "1",
+	}
+	for args, want := range cases {
+		if got := strings.Join(search(args), ","); got != want {
+			t.Errorf("search %s = %s, want %s", args, got, want)
+		}
+	}
+
+	if _, err = ParseQuery("鱼 type:unknown"); err == nil {
+		t.Error("未知的消息类型应当报错")
+	}
+
+	r, _ := a.Get("2")
+	before, after, err := a.Around(r, 1, nil)
+	if err != nil || len(before) != 1 || before[0].OId != "1" || len(after) != 1 || after[0].OId != "3" {
+		t.Fatalf("上下文错误：%v %v %v", before, after, err)
+	}
+}
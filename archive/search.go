@@ -0,0 +1,309 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const defaultLimit = 20
+
+var types = []string{TypeMsg, TypeRevoke, TypeRedPacketStatus, TypeBarrage, TypeDiscussChanged}
+
+// Query 搜索条件 为空的条件不限制
+type Query struct {
+	Keywords []string  // 同时包含这些关键词 不区分大小写
+	User     string    // 用户名 不区分大小写
+	Type     string    // 消息类型 revoke同时匹配已撤回的消息
+	From     time.Time // 包含
+	To       time.Time // 不包含
+	Limit    int       // 最多返回的数量 默认20
+
+	Exclude func(r *Record) bool // 不展示的记录 例如被屏蔽的用户 可以为空
+}
+
+// ParseQuery 解析搜索指令的参数 例如 摸鱼 user:alice type:msg from:2024-05-01 to:2024-05-02 limit:50
+// 日期为 2006-01-02 或者 2006-01-02T15:04 只有日期时to包含当天
+func ParseQuery(args string) (*Query, error) {
+	q := &Query{Limit: defaultLimit}
+	for _, field := range strings.Fields(args) {
+		name, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			q.Keywords = append(q.Keywords, field)
+			continue
+		}
+
+		var err error
+		switch strings.ToLower(name) {
+		case "user":
+			q.User = value
+		case "type":
+			q.Type, err = parseType(value)
+		case "from":
+			q.From, _, err = parseDate(value)
+		case "to":
+			var dateOnly bool
+			q.To, dateOnly, err = parseDate(value)
+			if dateOnly {
+				q.To = q.To.AddDate(0, 0, 1)
+			}
+		case "limit":
+			if q.Limit, err = strconv.Atoi(value); err == nil && q.Limit <= 0 {
+				err = errors.New("limit 应当大于0")
+			}
+		default:
+			q.Keywords = append(q.Keywords, field)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s：%w", field, err)
+		}
+	}
+	return q, nil
+}
+
+func parseType(s string) (string, error) {
+	for _, t := range types {
+		if strings.EqualFold(s, t) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("消息类型应当是 %s", strings.Join(types, "/"))
+}
+
+func parseDate(s string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04", s, time.Local)
+	if err != nil {
+		return time.Time{}, false, errors.New("日期格式应当是 2006-01-02 或者 2006-01-02T15:04")
+	}
+	return t, false, nil
+}
+
+func (q *Query) match(r *Record) bool {
+	if q.Exclude != nil && q.Exclude(r) {
+		return false
+	}
+	if q.User != "" && !strings.EqualFold(q.User, r.UserName) {
+		return false
+	}
+	if q.Type != "" && q.Type != r.Type && !(q.Type == TypeRevoke && r.Revoked) {
+		return false
+	}
+	if !q.From.IsZero() && r.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !r.Time.Before(q.To) {
+		return false
+	}
+	content := strings.ToLower(r.Content)
+	for _, k := range q.Keywords {
+		if !strings.Contains(content, strings.ToLower(k)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Search 按时间倒序返回符合条件的记录
+// 关键词通过二元组索引查找候选记录 没有可用的二元组时按时间遍历
+func (a *Archive) Search(q *Query) ([]*Record, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	var grams []string
+	for _, k := range q.Keywords {
+		grams = append(grams, ngrams(k)...)
+	}
+
+	var result []*Record
+	err := a.db.View(func(tx *bolt.Tx) error {
+		if len(grams) == 0 {
+			var err error
+			result, err = scan(tx, q, limit)
+			return err
+		}
+
+		for _, key := range lookup(tx, grams) {
+			r, err := get(tx, key)
+			if err != nil {
+				return err
+			}
+			if q.match(r) {
+				result = append(result, r)
+			}
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Time.After(result[j].Time)
+		})
+		if len(result) > limit {
+			result = result[:limit]
+		}
+		return nil
+	})
+	return result, err
+}
+
+// scan 从最新的记录开始遍历
+func scan(tx *bolt.Tx, q *Query, limit int) ([]*Record, error) {
+	var result []*Record
+	c := tx.Bucket(bucketTime).Cursor()
+	k, _ := c.Last()
+	if !q.To.IsZero() {
+		if k, _ = c.Seek(timeKey(q.To, "")); k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+	}
+	for ; k != nil && len(result) < limit; k, _ = c.Prev() {
+		if !q.From.IsZero() && parseTime(k).Before(q.From) {
+			break
+		}
+		r, err := get(tx, string(k[8:]))
+		if err != nil {
+			return nil, err
+		}
+		if q.match(r) {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// lookup 返回包含所有二元组的记录key
+func lookup(tx *bolt.Tx, grams []string) []string {
+	c := tx.Bucket(bucketIndex).Cursor()
+	var keys map[string]struct{}
+	for _, g := range grams {
+		prefix := append([]byte(g), 0)
+		next := make(map[string]struct{})
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			key := string(k[len(prefix):])
+			if _, ok := keys[key]; keys == nil || ok {
+				next[key] = struct{}{}
+			}
+		}
+		keys = next
+		if len(keys) == 0 {
+			return nil
+		}
+	}
+
+	result := make([]string, 0, len(keys))
+	for k := range keys {
+		result = append(result, k)
+	}
+	return result
+}
+
+// Around 返回记录前后各n条聊天消息 用于展示搜索结果的上下文 exclude可以为空
+func (a *Archive) Around(r *Record, n int, exclude func(r *Record) bool) (before, after []*Record, err error) {
+	err = a.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketTime).Cursor()
+		self := timeKey(r.Time, r.Key)
+
+		for k, _ := c.Seek(self); k != nil && len(before) < n; {
+			if k, _ = c.Prev(); k == nil {
+				break
+			}
+			v, err := get(tx, string(k[8:]))
+			if err != nil {
+				return err
+			}
+			if v.Type == TypeMsg && (exclude == nil || !exclude(v)) {
+				before = append([]*Record{v}, before...)
+			}
+		}
+
+		k, _ := c.Seek(self)
+		if bytes.Equal(k, self) {
+			k, _ = c.Next()
+		}
+		for ; k != nil && len(after) < n; k, _ = c.Next() {
+			v, err := get(tx, string(k[8:]))
+			if err != nil {
+				return err
+			}
+			if v.Type == TypeMsg && (exclude == nil || !exclude(v)) {
+				after = append(after, v)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// ngrams 把文本切分为二元组 中文没有空格分词 连续的字母数字和汉字都按字符切分
+func ngrams(s string) []string {
+	seen := make(map[string]struct{})
+	var result []string
+	var run []rune
+	flush := func() {
+		for i := 0; i+1 < len(run); i++ {
+			g := string(run[i : i+2])
+			if _, ok := seen[g]; !ok {
+				seen[g] = struct{}{}
+				result = append(result, g)
+			}
+		}
+		run = run[:0]
+	}
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			run = append(run, r)
+		} else {
+			flush()
+		}
+	}
+	flush()
+	return result
+}
+
+func indexKey(gram, key string) []byte {
+	k := make([]byte, 0, len(gram)+1+len(key))
+	k = append(k, gram...)
+	k = append(k, 0)
+	return append(k, key...)
+}
+
+func index(tx *bolt.Tx, r *Record) error {
+	b := tx.Bucket(bucketIndex)
+	for _, g := range ngrams(r.Content) {
+		if err := b.Put(indexKey(g, r.Key), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unindex(tx *bolt.Tx, r *Record) error {
+	b := tx.Bucket(bucketIndex)
+	for _, g := range ngrams(r.Content) {
+		if err := b.Delete(indexKey(g, r.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reindex 为没有索引的旧存档建立索引
+func reindex(tx *bolt.Tx) error {
+	return tx.Bucket(bucketRecords).ForEach(func(k, v []byte) error {
+		r, err := get(tx, string(k))
+		if err != nil {
+			return err
+		}
+		return index(tx, r)
+	})
+}
@@ -0,0 +1,372 @@
+package block
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fishpi/logger"
+)
+
+// 规则可以匹配的消息类型 与聊天室ws消息的type一致
+var types = []string{"msg", "barrager", "redPacketStatus", "revoke", "discussChanged"}
+
+// Message 需要判断是否屏蔽的消息 由各个模式的消息转换而来
+type Message struct {
+	Type     string
+	UserName string
+	Client   string
+	Content  string
+}
+
+// Rule 一条屏蔽规则 填写的条件需要全部满足
+type Rule struct {
+	ID      int       `json:"id"`
+	User    string    `json:"user,omitempty"`    // 用户名 不区分大小写
+	Client  string    `json:"client,omitempty"`  // 客户端 包含即匹配 不区分大小写 例如 Golang
+	Content string    `json:"content,omitempty"` // 消息内容的正则表达式
+	Type    string    `json:"type,omitempty"`    // 消息类型 例如 barrager
+	Expire  time.Time `json:"expire,omitempty"`  // 过期时间 零值为永久有效
+	Created time.Time `json:"created"`
+
+	re *regexp.Regexp
+}
+
+func (r *Rule) compile() (err error) {
+	if r.User == "" && r.Client == "" && r.Content == "" && r.Type == "" {
+		return errors.New("屏蔽规则至少需要一个条件")
+	}
+	if r.Content != "" {
+		if r.re, err = regexp.Compile(r.Content); err != nil {
+			return fmt.Errorf("content 正则表达式错误：%w", err)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) expired(now time.Time) bool {
+	return !r.Expire.IsZero() && !now.Before(r.Expire)
+}
+
+func (r *Rule) match(m *Message) bool {
+	if r.User != "" && !strings.EqualFold(r.User, m.UserName) {
+		return false
+	}
+	if r.Client != "" && !strings.Contains(strings.ToLower(m.Client), strings.ToLower(r.Client)) {
+		return false
+	}
+	if r.Type != "" && r.Type != m.Type {
+		return false
+	}
+	if r.re != nil && !r.re.MatchString(m.Content) {
+		return false
+	}
+	return true
+}
+
+func (r *Rule) String() string {
+	var conds []string
+	if r.User != "" {
+		conds = append(conds, "user:"+r.User)
+	}
+	if r.Client != "" {
+		conds = append(conds, "client:"+r.Client)
+	}
+	if r.Content != "" {
+		conds = append(conds, "content:"+strconv.Quote(r.Content))
+	}
+	if r.Type != "" {
+		conds = append(conds, "type:"+r.Type)
+	}
+	s := fmt.Sprintf("#%d %s", r.ID, strings.Join(conds, " "))
+	if !r.Expire.IsZero() {
+		s += " 有效期至 " + r.Expire.Format("2006-01-02 15:04:05")
+	}
+	return s
+}
+
+// ParseRule 解析屏蔽指令的参数 例如 alice client:Golang content:"^\d+$" type:barrager for:2h until:2024-05-01
+// 不带条件名的参数为用户名 for支持 30m 2h 7d 等时长
+func ParseRule(args []string, now time.Time) (*Rule, error) {
+	r := &Rule{Created: now}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, ":")
+		if !ok {
+			name, value = "user", arg
+		}
+		if value == "" {
+			return nil, fmt.Errorf("%s 不能为空", name)
+		}
+
+		switch strings.ToLower(name) {
+		case "user":
+			r.User = value
+		case "client":
+			r.Client = value
+		case "content":
+			r.Content = value
+		case "type":
+			t, err := parseType(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Type = t
+		case "for":
+			d, err := parseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s：%w", arg, err)
+			}
+			r.Expire = now.Add(d)
+		case "until":
+			t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				if t, err = time.ParseInLocation("2006-01-02T15:04", value, time.Local); err != nil {
+					return nil, fmt.Errorf("%s：日期格式应当是 2006-01-02 或者 2006-01-02T15:04", arg)
+				}
+			}
+			r.Expire = t
+		default:
+			return nil, fmt.Errorf("未知的条件：%s 可选 user client content type for until", name)
+		}
+	}
+	if err := r.compile(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func parseType(s string) (string, error) {
+	if strings.EqualFold(s, "barrage") {
+		return "barrager", nil
+	}
+	for _, t := range types {
+		if strings.EqualFold(s, t) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("消息类型应当是 %s", strings.Join(types, "/"))
+}
+
+// parseDuration 在 time.ParseDuration 的基础上支持天 例如 7d
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, errors.New("时长格式错误")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, errors.New("时长格式错误 例如 30m 2h 7d")
+	}
+	return d, nil
+}
+
+// List 屏蔽规则 保存在json文件中 文件被其他进程修改后自动重新加载
+type List struct {
+	path string
+
+	mu      sync.RWMutex
+	rules   []*Rule
+	nextID  int
+	modTime time.Time
+
+	now    func() time.Time
+	logger logger.Logger
+}
+
+// Open 读取规则文件 文件不存在时为空列表 第一次添加规则时创建
+func Open(path string, logger logger.Logger) (*List, error) {
+	l := &List{
+		path:   path,
+		nextID: 1,
+		now:    time.Now,
+		logger: logger.Named("block"),
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *List) Name() string {
+	return "block"
+}
+
+// Run 定时检查规则文件 其他进程修改后重新加载
+func (l *List) Run(ctx context.Context) error {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if info, err := os.Stat(l.path); err == nil && !info.ModTime().Equal(l.lastMod()) {
+				if err = l.load(); err != nil {
+					l.logger.Error("屏蔽规则重新加载失败 继续使用之前的规则", "err", err)
+				}
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (l *List) lastMod() time.Time {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.modTime
+}
+
+func (l *List) load() error {
+	info, err := os.Stat(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	body, err := os.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+
+	var rules []*Rule
+	if err = json.Unmarshal(body, &rules); err != nil {
+		return fmt.Errorf("屏蔽规则文件格式错误 %s：%w", l.path, err)
+	}
+	nextID := 1
+	for _, r := range rules {
+		if err = r.compile(); err != nil {
+			return fmt.Errorf("屏蔽规则 #%d：%w", r.ID, err)
+		}
+		nextID = max(nextID, r.ID+1)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules, l.nextID, l.modTime = rules, nextID, info.ModTime()
+	return nil
+}
+
+// save 调用时需要持有写锁 过期的规则不再保存
+func (l *List) save() error {
+	now := l.now()
+	rules := make([]*Rule, 0, len(l.rules))
+	for _, r := range l.rules {
+		if !r.expired(now) {
+			rules = append(rules, r)
+		}
+	}
+	body, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), "."+filepath.Base(l.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), l.path); err != nil {
+		return err
+	}
+
+	l.rules = rules
+	if info, err := os.Stat(l.path); err == nil {
+		l.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Add 添加规则并保存 返回分配的编号
+func (l *List) Add(r *Rule) (int, error) {
+	if err := r.compile(); err != nil {
+		return 0, err
+	}
+	if r.Created.IsZero() {
+		r.Created = l.now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r.ID = l.nextID
+	l.rules = append(l.rules, r)
+	if err := l.save(); err != nil {
+		l.rules = l.rules[:len(l.rules)-1]
+		return 0, err
+	}
+	l.nextID++
+	return r.ID, nil
+}
+
+// Remove 按编号或者用户名删除规则 返回删除的数量
+func (l *List) Remove(target string) (int, error) {
+	id, _ := strconv.Atoi(strings.TrimPrefix(target, "#"))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	old := l.rules
+	rules := make([]*Rule, 0, len(old))
+	for _, r := range old {
+		if r.ID == id || (r.User != "" && strings.EqualFold(r.User, target)) {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	n := len(old) - len(rules)
+	if n == 0 {
+		return 0, nil
+	}
+	l.rules = rules
+	if err := l.save(); err != nil {
+		l.rules = old
+		return 0, err
+	}
+	return n, nil
+}
+
+// Rules 当前有效的规则
+func (l *List) Rules() []*Rule {
+	now := l.now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var rules []*Rule
+	for _, r := range l.rules {
+		if !r.expired(now) {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// Block 消息是否被有效的规则屏蔽 l为nil时不屏蔽
+func (l *List) Block(m *Message) bool {
+	if l == nil {
+		return false
+	}
+	now := l.now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, r := range l.rules {
+		if !r.expired(now) && r.match(m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package block
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fishpi/logger"
+)
+
+func TestList(t *testing.T) {
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	path := filepath.Join(t.TempDir(), "blocks.json")
+	list, err := Open(path, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	list.now = func() time.Time { return now }
+
+	add := func(args ...string) *Rule {
+		t.Helper()
+		r, err := ParseRule(args, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = list.Add(r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	add("alice")
+	add("type:barrage", "for:1h")
+	add(`content:^\d+$`, "client:golang")
+
+	cases := []struct {
+		m     Message
+		block bool
+	}{
+		{Message{Type: "msg", UserName: "Alice", Content: "hi"}, true},
+		{Message{Type: "msg", UserName: "bob", Content: "hi"}, false},
+		{Message{Type: "barrager", UserName: "bob", Content: "hi"}, true},
+		{Message{Type: "msg", UserName: "bob", Client: "Golang/v0.0.3", Content: "123"}, true},
+		{Message{Type: "msg", UserName: "bob", Client: "Web/v2", Content: "123"}, false},
+	}
+	for i, c := range cases {
+		if got := list.Block(&c.m); got != c.block {
+			t.Errorf("case %d: block = %v, want %v", i, got, c.block)
+		}
+	}
+
+	// 过期后不再屏蔽
+	now = now.Add(2 * time.Hour)
+	if list.Block(&Message{Type: "barrager", UserName: "bob"}) {
+		t.Error("过期的规则不应当生效")
+	}
+	if len(list.Rules()) != 2 {
+		t.Errorf("有效规则数量：%d", len(list.Rules()))
+	}
+
+	// 重新打开后规则仍然存在 编号继续递增
+	if n, err := list.Remove("ALICE"); err != nil || n != 1 {
+		t.Fatalf("按用户名删除：%d %v", n, err)
+	}
+	reopened, err := Open(path, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := reopened.Rules()
+	if len(rules) != 1 || rules[0].ID != 3 || rules[0].re == nil {
+		t.Fatalf("重新打开后的规则：%v", rules)
+	}
+	if n, _ := reopened.Remove("#3"); n != 1 {
+		t.Error("按编号删除失败")
+	}
+
+	if _, err = ParseRule([]string{"for:1h"}, now); err == nil {
+		t.Error("没有条件的规则应当报错")
+	}
+	if _, err = ParseRule([]string{"content:("}, now); err == nil {
+		t.Error("错误的正则应当报错")
+	}
+}
@@ -0,0 +1,282 @@
+package bridge
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+)
+
+const unixPrefix = "unix:"
+
+const (
+	CommandSend          = "send"            // 发送聊天室消息
+	CommandOpenRedPacket = "open-red-packet" // 打开红包
+	CommandRevoke        = "revoke"          // 撤回消息
+)
+
+// Sdk 外部指令对应的FishPi接口
+type Sdk interface {
+	SendMsg(msg string) error
+	OpenRedPacket(oId, typ, gesture string) (string, error)
+	RevokeMsg(oId string) error
+}
+
+// Command 外部程序发送的指令
+type Command struct {
+	Command string `json:"command"`
+	Content string `json:"content,omitempty"` // send
+	OId     string `json:"oId,omitempty"`     // open-red-packet revoke
+	Gesture string `json:"gesture,omitempty"` // open-red-packet 猜拳红包 1-石头 2-剪刀 3-布 0-随机
+	Type    string `json:"type,omitempty"`    // open-red-packet 红包类型 用于红包账本 可以不填
+}
+
+type commandReply struct {
+	Code   int    `json:"code"`
+	Msg    string `json:"msg,omitempty"`
+	Result string `json:"result,omitempty"`
+}
+
+// event 推送给外部程序的事件
+type event struct {
+	Topic eventHandler.EventType `json:"topic"`
+	Time  int64                  `json:"time"`
+	Data  interface{}            `json:"data"`
+}
+
+// Bridge 通过本地HTTP(SSE)把总线上的事件推送给外部程序 并接收外部程序的指令
+type Bridge struct {
+	addr  string
+	token string
+
+	bus        *eventHandler.Bus
+	sdk        Sdk
+	packetType func(oId string) string // 按oId查找收到的红包的类型 未设置时由sdk查找
+	logger     logger.Logger
+}
+
+func NewBridge(addr, token string, bus *eventHandler.Bus, sdk Sdk, logger logger.Logger) *Bridge {
+	return &Bridge{
+		addr:   addr,
+		token:  token,
+		bus:    bus,
+		sdk:    sdk,
+		logger: logger.Named("bridge"),
+	}
+}
+
+// SetPacketType 设置查找红包类型的方法 指令中没有填写类型时使用
+func (b *Bridge) SetPacketType(f func(oId string) string) *Bridge {
+	b.packetType = f
+	return b
+}
+
+func (b *Bridge) Name() string {
+	return "bridge"
+}
+
+// Run 监听地址直到ctx结束
+func (b *Bridge) Run(ctx context.Context) error {
+	ln, err := b.listen()
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:     b.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	b.logger.Info("事件推送地址", "addr", b.addr)
+	if err = srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (b *Bridge) listen() (net.Listener, error) {
+	if path, ok := strings.CutPrefix(b.addr, unixPrefix); ok {
+		// 清理上次异常退出残留的socket文件
+		if _, err := os.Stat(path); err == nil {
+			_ = os.Remove(path)
+		}
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err = os.Chmod(path, 0600); err != nil {
+			_ = ln.Close()
+			return nil, err
+		}
+		return ln, nil
+	}
+	ln, err := net.Listen("tcp", b.addr)
+	if err != nil {
+		return nil, err
+	}
+	// 没有令牌时不对外暴露事件
+	if addr, ok := ln.Addr().(*net.TCPAddr); ok && b.token == "" && !addr.IP.IsLoopback() {
+		_ = ln.Close()
+		return nil, fmt.Errorf("未配置令牌时只能监听本机地址：%s", b.addr)
+	}
+	return ln, nil
+}
+
+// Handler 对外提供的接口
+//
+//	GET  /events?topic=chatroom/*  SSE推送事件 topic为空时推送所有事件
+//	POST /command                  执行指令 需要 Authorization: Bearer {token}
+func (b *Bridge) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", b.handleEvents)
+	mux.HandleFunc("POST /command", b.handleCommand)
+	return mux
+}
+
+func (b *Bridge) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if b.token != "" && !b.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	pattern := r.URL.Query().Get("topic")
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	// 外部程序处理过慢时丢弃旧事件 不影响聊天室本身
+	events := make(chan eventHandler.Event, 256)
+	sub := eventHandler.SubscribePattern(b.bus, pattern, func(ev eventHandler.Event) {
+		select {
+		case events <- ev:
+		case <-r.Context().Done():
+		}
+	}, eventHandler.WithBuffer(256), eventHandler.WithOverflow(eventHandler.OverflowDropOldest))
+	defer sub.Unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case ev := <-events:
+			body, err := json.Marshal(&event{Topic: ev.Topic, Time: time.Now().UnixMilli(), Data: payload(ev.Data)})
+			if err != nil {
+				b.logger.Error("marshal event failed", "event", ev.Topic, "err", err)
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Topic, body); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (b *Bridge) handleCommand(w http.ResponseWriter, r *http.Request) {
+	if b.token == "" {
+		writeReply(w, http.StatusForbidden, &commandReply{Code: -1, Msg: "未配置bridge.token 不接受指令"})
+		return
+	}
+	if !b.authorized(r) {
+		writeReply(w, http.StatusUnauthorized, &commandReply{Code: -1, Msg: "unauthorized"})
+		return
+	}
+
+	var cmd Command
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&cmd); err != nil {
+		writeReply(w, http.StatusBadRequest, &commandReply{Code: -1, Msg: err.Error()})
+		return
+	}
+
+	result, err := b.exec(&cmd)
+	if err != nil {
+		writeReply(w, http.StatusOK, &commandReply{Code: -1, Msg: err.Error()})
+		return
+	}
+	writeReply(w, http.StatusOK, &commandReply{Code: 0, Result: result})
+}
+
+func (b *Bridge) exec(cmd *Command) (string, error) {
+	switch cmd.Command {
+	case CommandSend:
+		if strings.TrimSpace(cmd.Content) == "" {
+			return "", errors.New("content不能为空")
+		}
+		return "", b.sdk.SendMsg(cmd.Content)
+	case CommandOpenRedPacket:
+		if cmd.OId == "" {
+			return "", errors.New("oId不能为空")
+		}
+		typ := cmd.Type
+		if typ == "" && b.packetType != nil {
+			typ = b.packetType(cmd.OId)
+		}
+		return b.sdk.OpenRedPacket(cmd.OId, typ, cmd.Gesture)
+	case CommandRevoke:
+		if cmd.OId == "" {
+			return "", errors.New("oId不能为空")
+		}
+		return "", b.sdk.RevokeMsg(cmd.OId)
+	default:
+		return "", fmt.Errorf("未知指令：%s", cmd.Command)
+	}
+}
+
+func (b *Bridge) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(b.token)) == 1
+}
+
+// payload websocket原始消息本身就是JSON 直接透传
+func payload(data interface{}) interface{} {
+	if bytes, ok := data.([]byte); ok {
+		if json.Valid(bytes) {
+			return json.RawMessage(bytes)
+		}
+		return string(bytes)
+	}
+	return data
+}
+
+func writeReply(w http.ResponseWriter, status int, reply *commandReply) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(reply)
+}
@@ -0,0 +1,141 @@
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+)
+
+type fakeSdk struct {
+	sent   []string
+	opened []string // oId:type
+}
+
+func (f *fakeSdk) SendMsg(msg string) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func (f *fakeSdk) OpenRedPacket(oId, typ, gesture string) (string, error) {
+	f.opened = append(f.opened, oId+":"+typ)
+	return "opened " + oId, nil
+}
+
+func (f *fakeSdk) RevokeMsg(oId string) error {
+	return nil
+}
+
+func TestCommand(t *testing.T) {
+	sdk := &fakeSdk{}
+	b := NewBridge("", "secret", eventHandler.NewBus("test", logger.NewLogger(os.Stderr, slog.LevelWarn)), sdk, logger.NewLogger(os.Stderr, slog.LevelWarn))
+	srv := httptest.NewServer(b.Handler())
+	defer srv.Close()
+
+	post := func(token string, cmd *Command) (int, *commandReply) {
+		body, _ := json.Marshal(cmd)
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/command", bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		reply := new(commandReply)
+		_ = json.NewDecoder(resp.Body).Decode(reply)
+		return resp.StatusCode, reply
+	}
+
+	if code, _ := post("wrong", &Command{Command: CommandSend, Content: "hi"}); code != http.StatusUnauthorized {
+		t.Fatalf("wrong token accepted: %d", code)
+	}
+	if _, reply := post("secret", &Command{Command: CommandSend, Content: "hi"}); reply.Code != 0 {
+		t.Fatalf("send failed: %+v", reply)
+	}
+	if len(sdk.sent) != 1 || sdk.sent[0] != "hi" {
+		t.Fatalf("unexpected sent: %v", sdk.sent)
+	}
+	if _, reply := post("secret", &Command{Command: CommandOpenRedPacket, OId: "123"}); reply.Result != "opened 123" {
+		t.Fatalf("unexpected reply: %+v", reply)
+	}
+	// 没有填写类型时从收到的红包中查找 填写的类型优先
+	b.SetPacketType(func(oId string) string { return map[string]string{"456": "heartbeat"}[oId] })
+	post("secret", &Command{Command: CommandOpenRedPacket, OId: "456"})
+	post("secret", &Command{Command: CommandOpenRedPacket, OId: "456", Type: "random"})
+	if fmt.Sprint(sdk.opened) != "[123: 456:heartbeat 456:random]" {
+		t.Fatalf("unexpected opened: %v", sdk.opened)
+	}
+	if _, reply := post("secret", &Command{Command: "unknown"}); reply.Code == 0 {
+		t.Fatal("unknown command accepted")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	bus := eventHandler.NewBus("test", logger.NewLogger(os.Stderr, slog.LevelWarn))
+	b := NewBridge("", "", bus, &fakeSdk{}, logger.NewLogger(os.Stderr, slog.LevelWarn))
+	srv := httptest.NewServer(b.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events?topic=chatroom/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	chatroom := bus.Namespace("chatroom")
+	go func() {
+		// 等待订阅建立
+		time.Sleep(100 * time.Millisecond)
+		eventHandler.Publish(chatroom, eventHandler.TopicWsMsg, []byte(`{"type":"msg","oId":"1"}`))
+	}()
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var ev struct {
+			Topic string          `json:"topic"`
+			Data  json.RawMessage `json:"data"`
+		}
+		if err = json.Unmarshal([]byte(data), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Topic != "chatroom/"+eventHandler.WsMsg || string(ev.Data) != `{"type":"msg","oId":"1"}` {
+			t.Fatalf("unexpected event: %s", data)
+		}
+		return
+	}
+}
+
+func TestListenWithoutToken(t *testing.T) {
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
+	b := NewBridge("0.0.0.0:0", "", eventHandler.NewBus("test", l), &fakeSdk{}, l)
+	if ln, err := b.listen(); err == nil {
+		_ = ln.Close()
+		t.Fatal("listening on all interfaces without token")
+	}
+	b = NewBridge("127.0.0.1:0", "", eventHandler.NewBus("test", l), &fakeSdk{}, l)
+	ln, err := b.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ln.Close()
+}
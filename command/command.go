@@ -0,0 +1,237 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"fishpi/logger"
+)
+
+// ErrUnknown 指令不存在
+var ErrUnknown = errors.New("无效指令")
+
+// Command 一条终端指令
+type Command struct {
+	Name    string
+	Aliases []string // 以-或者+结尾的别名直接连接参数 兼容旧的指令格式 例如 info-{username}
+	Usage   string   // 参数格式 例如 {username} [page]
+	Help    string   // 一句话说明
+	MinArgs int      // 最少参数数量 不足时输出用法
+
+	// Complete 补全最后一个参数 args至少有一个元素 可以为空
+	Complete func(args []string) []string
+	Run      func(c *Context) error
+}
+
+// Context 一次指令调用
+type Context struct {
+	Name string   // 实际输入的指令名或者别名
+	Args []string // 参数 支持双引号包含空格
+	Raw  string   // 指令名之后的原始内容 用于消息等需要保留空格的参数
+	Out  logger.Display
+}
+
+// Arg 第i个参数 不存在时返回空字符串
+func (c *Context) Arg(i int) string {
+	if i < len(c.Args) {
+		return c.Args[i]
+	}
+	return ""
+}
+
+// Registry 指令注册表 ws msg simple等模式共用
+type Registry struct {
+	commands []*Command
+	names    map[string]*Command // 指令名和别名
+	prefixes []string            // 以-或者+结尾的别名 按长度倒序 优先匹配更长的别名
+}
+
+// NewRegistry 创建注册表 并注册help指令
+func NewRegistry() *Registry {
+	r := &Registry{names: make(map[string]*Command)}
+	r.Register(&Command{
+		Name:     "help",
+		Usage:    "[指令]",
+		Help:     "查看帮助信息",
+		Complete: r.completeName,
+		Run: func(c *Context) error {
+			c.Out.Print(r.Help(c.Arg(0)))
+			return nil
+		},
+	})
+	return r
+}
+
+// Register 注册指令 指令名或者别名重复时panic
+func (r *Registry) Register(cmds ...*Command) *Registry {
+	for _, cmd := range cmds {
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			if _, ok := r.names[name]; ok {
+				panic(fmt.Sprintf("command %s already registered", name))
+			}
+			r.names[name] = cmd
+			if isPrefix(name) {
+				r.prefixes = append(r.prefixes, name)
+			}
+		}
+		r.commands = append(r.commands, cmd)
+	}
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i]) > len(r.prefixes[j])
+	})
+	return r
+}
+
+// Commands 按注册顺序返回所有指令
+func (r *Registry) Commands() []*Command {
+	return r.commands
+}
+
+// Lookup 按指令名或者别名查找指令
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	cmd, ok := r.names[name]
+	return cmd, ok
+}
+
+// Parse 解析一行输入 返回指令和调用参数 找不到指令时返回ErrUnknown
+func (r *Registry) Parse(line string) (*Command, *Context, error) {
+	line = strings.TrimSpace(line)
+	name, raw, _ := strings.Cut(line, " ")
+	cmd, ok := r.names[name]
+	if !ok || isPrefix(name) {
+		ok = false
+		for _, prefix := range r.prefixes {
+			if strings.HasPrefix(line, prefix) {
+				cmd, ok = r.names[prefix], true
+				name, raw = prefix, strings.TrimPrefix(line, prefix)
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("%w：%s", ErrUnknown, name)
+	}
+
+	raw = strings.TrimSpace(raw)
+	c := &Context{Name: name, Args: split(raw), Raw: raw}
+	if len(c.Args) < cmd.MinArgs {
+		return nil, nil, fmt.Errorf("参数不足 用法：%s", usage(cmd))
+	}
+	return cmd, c, nil
+}
+
+// Exec 执行一行输入 结果输出到out
+func (r *Registry) Exec(line string, out logger.Display) error {
+	cmd, c, err := r.Parse(line)
+	if err != nil {
+		return err
+	}
+	c.Out = out
+	return cmd.Run(c)
+}
+
+// Help 所有指令的帮助信息 name不为空时只输出该指令
+func (r *Registry) Help(name string) string {
+	if name != "" {
+		cmd, ok := r.names[name]
+		if !ok {
+			return fmt.Sprintf("%s：%s", ErrUnknown, name)
+		}
+		return help(cmd)
+	}
+
+	lines := make([]string, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		lines = append(lines, help(cmd))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Complete 补全一行输入 返回补全后的整行
+func (r *Registry) Complete(line string) []string {
+	name, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return r.completeName([]string{name})
+	}
+
+	cmd, found := r.names[name]
+	if !found || cmd.Complete == nil {
+		return nil
+	}
+	args := split(rest)
+	if len(args) == 0 || strings.HasSuffix(rest, " ") {
+		args = append(args, "")
+	}
+	head := strings.TrimSuffix(line, args[len(args)-1])
+
+	var result []string
+	for _, v := range cmd.Complete(args) {
+		result = append(result, head+v)
+	}
+	return result
+}
+
+// completeName 补全指令名 不包含直接连接参数的别名
+func (r *Registry) completeName(args []string) []string {
+	prefix := args[len(args)-1]
+	var result []string
+	for _, cmd := range r.commands {
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			if !isPrefix(name) && strings.HasPrefix(name, prefix) {
+				result = append(result, name)
+			}
+		}
+	}
+	return result
+}
+
+func help(cmd *Command) string {
+	s := usage(cmd)
+	if len(cmd.Aliases) != 0 {
+		s += fmt.Sprintf(" (%s)", strings.Join(cmd.Aliases, " "))
+	}
+	return s + " - " + cmd.Help
+}
+
+func usage(cmd *Command) string {
+	if cmd.Usage == "" {
+		return cmd.Name
+	}
+	return cmd.Name + " " + cmd.Usage
+}
+
+func isPrefix(name string) bool {
+	return strings.HasSuffix(name, "-") || strings.HasSuffix(name, "+")
+}
+
+// split 按空格切分参数 双引号中的空格不切分
+func split(s string) []string {
+	var (
+		args   []string
+		sb     strings.Builder
+		quoted bool
+		has    bool
+	)
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			has = true
+		case r == ' ' && !quoted:
+			if has {
+				args = append(args, sb.String())
+				sb.Reset()
+				has = false
+			}
+		default:
+			sb.WriteRune(r)
+			has = true
+		}
+	}
+	if has {
+		args = append(args, sb.String())
+	}
+	return args
+}
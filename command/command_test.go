@@ -0,0 +1,89 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type output struct {
+	lines []string
+}
+
+func (o *output) Printf(format string, a ...interface{}) {
+	o.Print(fmt.Sprintf(format, a...))
+}
+
+func (o *output) Print(msg string) {
+	o.lines = append(o.lines, msg)
+}
+
+func TestRegistry(t *testing.T) {
+	var got *Context
+	run := func(c *Context) error {
+		got = c
+		return nil
+	}
+	r := NewRegistry().Register(
+		&Command{Name: "info", Aliases: []string{"info-"}, Usage: "{username}", Help: "查询用户信息", MinArgs: 1, Run: run},
+		&Command{Name: "bb-list", Aliases: []string{"bb-list-"}, Help: "获取明月清风", Run: run},
+		&Command{Name: "bb", Aliases: []string{"bb-"}, Help: "发布明月清风", Run: run},
+		&Command{Name: "open", Aliases: []string{"0", "1"}, Help: "打开红包", Run: run,
+			Complete: func(args []string) []string { return []string{"0", "1"} }},
+	)
+	out := new(output)
+
+	cases := []struct {
+		line string
+		name string
+		args []string
+		raw  string
+	}{
+		{"info alice", "info", []string{"alice"}, "alice"},
+		{"info-alice", "info-", []string{"alice"}, "alice"},
+		{"bb-list-20-1", "bb-list-", []string{"20-1"}, "20-1"},
+		{"bb-list 20 1", "bb-list", []string{"20", "1"}, "20 1"},
+		{`bb "hello world" !`, "bb", []string{"hello world", "!"}, `"hello world" !`},
+		{"bb-摸鱼", "bb-", []string{"摸鱼"}, "摸鱼"},
+		{"1", "1", nil, ""},
+	}
+	for _, c := range cases {
+		got = nil
+		if err := r.Exec(c.line, out); err != nil {
+			t.Fatalf("%s: %s", c.line, err)
+		}
+		if got.Name != c.name || !reflect.DeepEqual(got.Args, c.args) || got.Raw != c.raw {
+			t.Errorf("%s: got %s %q %q", c.line, got.Name, got.Args, got.Raw)
+		}
+	}
+
+	if err := r.Exec("hello", out); !errors.Is(err, ErrUnknown) {
+		t.Errorf("未注册的指令应当返回ErrUnknown：%v", err)
+	}
+	if err := r.Exec("info", out); err == nil || !strings.Contains(err.Error(), "info {username}") {
+		t.Errorf("参数不足时应当输出用法：%v", err)
+	}
+
+	if err := r.Exec("help info", out); err != nil || out.lines[0] != "info {username} (info-) - 查询用户信息" {
+		t.Errorf("help: %v %q", err, out.lines)
+	}
+
+	if got := r.Complete("b"); !reflect.DeepEqual(got, []string{"bb-list", "bb"}) {
+		t.Errorf("补全指令名：%q", got)
+	}
+	if got := r.Complete("open "); !reflect.DeepEqual(got, []string{"open 0", "open 1"}) {
+		t.Errorf("补全参数：%q", got)
+	}
+	if got := r.Complete("help in"); !reflect.DeepEqual(got, []string{"help info"}) {
+		t.Errorf("补全help参数：%q", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("重复注册应当panic")
+		}
+	}()
+	r.Register(&Command{Name: "info"})
+}
@@ -3,30 +3,42 @@ package config
 import (
 	"crypto/md5"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"strings"
+	"sync"
 
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	path string
-
-	FishPi   *FishPi   `yaml:"fishPi"`
-	Settings *Settings `yaml:"settings"`
-	Ice      *Ice      `yaml:"ice"`
-	Elves    *Elves    `yaml:"elves"`
+	mu   sync.Mutex // 保护raw和noPrompt raw热加载时会被替换
+	raw  *yaml.Node // 配置文件本身的内容 保存时只修改其中的字段 不会写入环境变量和命令行参数
+
+	FishPi    *FishPi    `yaml:"fishPi"`
+	Settings  *Settings  `yaml:"settings"`
+	Ice       *Ice       `yaml:"ice"`
+	Elves     *Elves     `yaml:"elves"`
+	Bridge    *Bridge    `yaml:"bridge"`
+	Filter    *Filter    `yaml:"filter"`
+	Log       *Log       `yaml:"log"`
+	Archive   *Archive   `yaml:"archive"`
+	Notify    *Notify    `yaml:"notify"`
+	RedPacket *RedPacket `yaml:"redPacket"`
+	Transform *Transform `yaml:"transform"`
+	Secrets   *Secrets   `yaml:"secrets"`
+
+	secrets  *secretStore // 已解密的密钥文件 未配置或者尚未创建时为nil
+	noPrompt bool         // 启动完成后不再在终端中输入密钥文件口令
 }
 
 type FishPi struct {
 	ApiBase     string `yaml:"apiBase"`
 	UserAgent   string `yaml:"userAgent"`
-	ApiKey      string `yaml:"apiKey"`
+	ApiKey      string `yaml:"apiKey" secret:"true"`
 	Username    string `yaml:"username"`
-	Password    string `yaml:"password"`
-	PasswordMd5 string `yaml:"passwordMd5"`
-	MfaCode     string `yaml:"mfaCode"`
+	Password    string `yaml:"password" secret:"true"`
+	PasswordMd5 string `yaml:"passwordMd5" secret:"true"`
+	MfaCode     string `yaml:"mfaCode" secret:"true"`
 }
 
 type Settings struct {
@@ -36,49 +48,84 @@ type Settings struct {
 
 type Ice struct {
 	Url      string `yaml:"url"`
-	Ck       string `yaml:"ck"`
+	Ck       string `yaml:"ck" secret:"true"`
 	Username string `yaml:"username"`
 	Uid      string `yaml:"uid"`
 }
 
-type Elves struct {
-	Token string `yaml:"token"`
+// Filter 消息过滤规则 修改后无需重启
+type Filter struct {
+	BlockUsers []string `yaml:"blockUsers"` // 屏蔽这些用户的消息
+	Keywords   []string `yaml:"keywords"`   // 屏蔽包含这些关键词的消息
+	BlockFile  string   `yaml:"blockFile"`  // 通过block指令添加的屏蔽规则 相对路径基于配置文件所在目录 修改后需要重启
 }
 
-func NewConfig(path string) (*Config, error) {
-	body, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
+// Archive 聊天记录存档
+type Archive struct {
+	Path          string `yaml:"path"`          // 存档文件 相对路径基于配置文件所在目录
+	RetentionDays int    `yaml:"retentionDays"` // 保留天数 0为永久保留
+	Disable       bool   `yaml:"disable"`       // 是否关闭存档
+}
 
-	var c Config
-	if err = yaml.Unmarshal(body, &c); err != nil {
-		return nil, err
-	}
-	c.FishPi.Init()
-	c.path = path
+// Notify @自己、关键词和关注用户的提醒 修改后无需重启
+type Notify struct {
+	Mention    bool     `yaml:"mention"`    // 有人@自己时提醒
+	Keywords   []string `yaml:"keywords"`   // 消息包含这些关键词时提醒
+	WatchUsers []string `yaml:"watchUsers"` // 这些用户发言时提醒
+	Notifiers  []string `yaml:"notifiers"`  // 提醒方式 bell highlight command webhook
+	Command    string   `yaml:"command"`    // command提醒执行的命令 提醒内容通过环境变量传入
+	Webhook    string   `yaml:"webhook"`    // webhook提醒推送的地址
+	QuietHours string   `yaml:"quietHours"` // 免打扰时段 例如 23:00-07:00 期间只高亮显示
+	RateLimit  int      `yaml:"rateLimit"`  // 同一条规则两次提醒的最小间隔 单位为秒 0为不限制
+}
+
+// RedPacket 自动抢红包和红包账本
+type RedPacket struct {
+	Auto   bool     `yaml:"auto"`   // 是否自动打开红包
+	Rules  []string `yaml:"rules"`  // 每种红包一条规则 没有规则的红包不自动打开 例如 random delay:1s-3s min:5
+	Ledger string   `yaml:"ledger"` // 红包账本 记录打开的每个红包 相对路径基于配置文件所在目录 修改后需要重启
+}
+
+// Transform 消息展示前的改写插件 所有前端共用 修改后无需重启
+type Transform struct {
+	Disable   []string `yaml:"disable"`   // 关闭的插件 kaibai weather links tails emoji
+	KaibaiUrl string   `yaml:"kaibaiUrl"` // 神秘代码的解码地址 为空时使用默认地址 令牌使用 elves.token
+}
 
-	return &c, err
+// Log 诊断日志 聊天内容不会写入
+type Log struct {
+	Level      string `yaml:"level"`      // debug info warn error 修改后无需重启
+	File       string `yaml:"file"`       // 日志文件 为空时输出到标准错误 simple模式下不输出
+	MaxSize    int    `yaml:"maxSize"`    // 每个日志文件的最大尺寸 单位为MB
+	MaxBackups int    `yaml:"maxBackups"` // 保留的旧日志文件的最大数量
+	MaxAge     int    `yaml:"maxAge"`     // 保留的旧日志文件的最大天数
+	Compress   bool   `yaml:"compress"`   // 是否压缩旧日志文件
+}
+
+type Elves struct {
+	Token string `yaml:"token" secret:"true"`
+}
+
+type Bridge struct {
+	Addr  string `yaml:"addr"`                // 监听地址 例如 127.0.0.1:7788 或者 unix:/tmp/fishpi.sock 为空则不开启 未配置token时只能监听本机地址
+	Token string `yaml:"token" secret:"true"` // 外部程序调用时使用的令牌 为空则只能订阅事件
+}
+
+// NewConfig 读取配置文件并叠加环境变量
+func NewConfig(path string) (*Config, error) {
+	return Load(path, nil)
 }
 
 func (c *Config) UpdateApiKey(apiKey string) error {
 	c.FishPi.ApiKey = apiKey
 
-	return c.save()
+	return c.update("fishPi", "apiKey", apiKey)
 }
 
 func (c *Config) UpdateCK(ck string) error {
 	c.Ice.Ck = ck
 
-	return c.save()
-}
-
-func (c *Config) save() error {
-	body, err := yaml.Marshal(c)
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(c.path, body, os.ModePerm)
+	return c.update("ice", "ck", ck)
 }
 
 func (f *FishPi) Init() {
@@ -0,0 +1,388 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSampleConfig(t *testing.T) {
+	if _, err := NewConfig("../config.yml"); err != nil {
+		t.Fatalf("sample config invalid: %s", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	path := writeConfig(t, `
+settings:
+  wsInterval: -1
+ice:
+  url: "https://game.yuis.cc/wss"
+bridge:
+  addr: "localhost"
+`)
+	_, err := NewConfig(path)
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("want ValidationError, got %v", err)
+	}
+	for _, field := range []string{"fishPi.username", "fishPi.apiKey", "settings.wsInterval", "ice.url", "bridge.addr"} {
+		found := false
+		for _, p := range ve.Problems {
+			if strings.Contains(p, field) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing problem for %s: %v", field, ve.Problems)
+		}
+	}
+}
+
+func TestBridgeAddr(t *testing.T) {
+	for _, tt := range []struct {
+		addr, token string
+		ok          bool
+	}{
+		{"127.0.0.1:7788", "", true},
+		{"localhost:7788", "", true},
+		{"[::1]:7788", "", true},
+		{"unix:/tmp/fishpi.sock", "", true},
+		{"0.0.0.0:7788", "", false},
+		{":7788", "", false},
+		{"192.168.1.2:7788", "", false},
+		{"0.0.0.0:7788", "secret", true},
+	} {
+		c := &Config{FishPi: &FishPi{Username: "test", ApiKey: "key"}, Bridge: &Bridge{Addr: tt.addr, Token: tt.token}}
+		c.setDefaults()
+		if err := c.Validate(); (err == nil) != tt.ok {
+			t.Errorf("addr %q token %q: %v", tt.addr, tt.token, err)
+		}
+	}
+}
+
+func TestMissingFishPi(t *testing.T) {
+	// 配置文件中没有fishPi 账号由环境变量提供
+	path := writeConfig(t, `
+settings:
+  msgCacheNum: 10
+`)
+	t.Setenv("FISHPI_USERNAME", "env-user")
+	t.Setenv("FISHPI_API_KEY", "env-key")
+	c, err := NewConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FishPi.Username != "env-user" || c.FishPi.ApiBase != DefaultApiBase {
+		t.Errorf("fishPi: %+v", c.FishPi)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	path := writeConfig(t, `
+fishPi:
+  username: "test"
+  password: "test"
+`)
+	c, err := NewConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FishPi.ApiBase != DefaultApiBase {
+		t.Errorf("apiBase default: %s", c.FishPi.ApiBase)
+	}
+	if c.Settings.MsgCacheNum != defaultMsgCacheNum || c.Settings.WsInterval != defaultWsInterval {
+		t.Errorf("settings default: %+v", c.Settings)
+	}
+	if c.Ice == nil || c.Elves == nil || c.Bridge == nil {
+		t.Errorf("optional sections should not be nil")
+	}
+	if c.FishPi.PasswordMd5 != "098f6bcd4621d373cade4e832627b4f6" {
+		t.Errorf("passwordMd5: %s", c.FishPi.PasswordMd5)
+	}
+}
+
+func TestLayers(t *testing.T) {
+	path := writeConfig(t, `fishPi:
+  username: "file-user" # 注释
+  password: "file-password"
+  unknownKey: "keep"
+settings:
+  msgCacheNum: 10
+`)
+	t.Setenv("FISHPI_USERNAME", "env-user")
+	t.Setenv("FISHPI_API_KEY", "env-key")
+	t.Setenv("FISHPI_SETTINGS_MSG_CACHE_NUM", "30")
+
+	c, err := Load(path, Overrides{"settings.msgCacheNum": "40"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FishPi.Username != "env-user" || c.FishPi.ApiKey != "env-key" {
+		t.Errorf("env not applied: %+v", c.FishPi)
+	}
+	if c.Settings.MsgCacheNum != 40 {
+		t.Errorf("flag not applied: %d", c.Settings.MsgCacheNum)
+	}
+	if masked := c.Masked(); strings.Contains(masked, "env-key") || strings.Contains(masked, "file-password") {
+		t.Errorf("secret leaked: %s", masked)
+	}
+
+	// 保存时只写入配置文件本身的内容
+	if err = c.UpdateCK("new-ck"); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := os.ReadFile(path)
+	for _, want := range []string{"file-user", "unknownKey", "new-ck"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("saved config missing %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(string(body), "env-key") {
+		t.Errorf("env value written to file:\n%s", body)
+	}
+}
+
+func TestSavePreservesFile(t *testing.T) {
+	path := writeConfig(t, `# 鱼排配置
+fishPi:
+  username: "test" # 用户名
+  apiKey: "old"
+  custom: keep
+# 冰冰配置
+ice:
+  ck: ""
+`)
+	c, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.UpdateApiKey("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.UpdateCK("ck"); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := os.ReadFile(path)
+	for _, want := range []string{"# 鱼排配置", "# 用户名", "custom: keep", "# 冰冰配置", `apiKey: "new"`, `ck: "ck"`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("saved config missing %s:\n%s", want, body)
+		}
+	}
+	if strings.Index(string(body), "username") > strings.Index(string(body), "apiKey") {
+		t.Errorf("field order changed:\n%s", body)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("unexpected permission: %o", perm)
+	}
+	backup, err := os.ReadFile(path + ".bak.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(backup), `apiKey: "new"`) || strings.Contains(string(backup), `ck: "ck"`) {
+		t.Errorf("unexpected backup:\n%s", backup)
+	}
+	if _, err = os.Stat(path + ".bak.2"); err != nil {
+		t.Errorf("backup not rotated: %s", err)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	path := writeConfig(t, `fishPi:
+  username: "test"
+  password: "plain" # 明文密码
+  apiKey: "key"
+elves:
+  token: "elves"
+`)
+	t.Setenv(PassphraseEnv, "passphrase")
+
+	c, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := c.MigrateSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("unexpected migrated count: %d", n)
+	}
+
+	body, _ := os.ReadFile(path)
+	for _, plain := range []string{"plain", "key", "elves"} {
+		if strings.Contains(string(body), `"`+plain+`"`) {
+			t.Errorf("plaintext %s left in config:\n%s", plain, body)
+		}
+	}
+	secrets, _ := os.ReadFile(path + ".secrets")
+	if strings.Contains(string(secrets), "plain") {
+		t.Errorf("secrets file not encrypted:\n%s", secrets)
+	}
+
+	if c, err = Load(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.FishPi.Password != "plain" || c.FishPi.ApiKey != "key" || c.Elves.Token != "elves" {
+		t.Errorf("secrets not loaded: %+v %+v", c.FishPi, c.Elves)
+	}
+
+	// 更新后的敏感字段只写入密钥文件
+	if err = c.UpdateApiKey("new"); err != nil {
+		t.Fatal(err)
+	}
+	if body, _ = os.ReadFile(path); strings.Contains(string(body), "new") {
+		t.Errorf("updated secret written to config:\n%s", body)
+	}
+	t.Setenv("FISHPI_ELVES_TOKEN", "env")
+	if c, err = Load(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.FishPi.ApiKey != "new" || c.Elves.Token != "env" {
+		t.Errorf("unexpected precedence: %s %s", c.FishPi.ApiKey, c.Elves.Token)
+	}
+
+	t.Setenv(PassphraseEnv, "wrong")
+	if _, err = Load(path, nil); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("wrong passphrase accepted: %v", err)
+	}
+}
+
+func TestSecretsNoPrompt(t *testing.T) {
+	path := writeConfig(t, `fishPi:
+  username: "test"
+  apiKey: "key"
+secrets:
+  file: "config.secrets"
+`)
+	t.Setenv(PassphraseEnv, "")
+	_ = os.Unsetenv(PassphraseEnv)
+
+	// 启动时密钥文件还不存在 运行中更新敏感字段时不在终端中输入口令
+	c, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.DisablePrompt()
+	if err = c.UpdateCK("ck"); !errors.Is(err, errNoPrompt) {
+		t.Fatalf("want errNoPrompt, got %v", err)
+	}
+
+	// 热加载时遇到运行中创建的密钥文件同样不输入口令
+	s, err := newSecrets(c.secretsPath(), false)
+	if !errors.Is(err, errNoPrompt) || s != nil {
+		t.Fatalf("want errNoPrompt, got %v", err)
+	}
+	t.Setenv(PassphraseEnv, "passphrase")
+	if s, err = newSecrets(c.secretsPath(), false); err != nil {
+		t.Fatal(err)
+	}
+	s.set("ice.ck", "secret-ck")
+	if err = s.save(); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Unsetenv(PassphraseEnv)
+	if _, err = load(path, nil, c); !errors.Is(err, errNoPrompt) {
+		t.Fatalf("want errNoPrompt, got %v", err)
+	}
+
+	// 已经解密的密钥文件直接复用
+	c.secrets = s
+	next, err := load(path, nil, c)
+	if err != nil || next.Ice.Ck != "secret-ck" {
+		t.Fatalf("reload with unlocked secrets: %v", err)
+	}
+	if err = c.UpdateCK("new-ck"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	path := writeConfig(t, `fishPi:
+  username: "test"
+  apiKey: "key"
+settings:
+  wsInterval: 3
+`)
+	c, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := eventHandler.NewBus("test", logger.NewLogger(os.Stderr, slog.LevelWarn))
+	reloads := make(chan *Reload, 1)
+	eventHandler.Subscribe(bus, TopicReload, func(r *Reload) {
+		reloads <- r
+	})
+	w := NewWatcher(c, nil, time.Second, bus, logger.NewLogger(os.Stderr, slog.LevelWarn))
+
+	edit := func(body string) *Reload {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+		w.modTime = time.Time{}
+		w.check()
+		select {
+		case r := <-reloads:
+			return r
+		case <-time.After(time.Second):
+			t.Fatal("no reload event")
+			return nil
+		}
+	}
+
+	r := edit(`fishPi:
+  username: "other"
+  apiKey: "key"
+settings:
+  wsInterval: 5
+filter:
+  keywords: ["广告"]
+`)
+	if r.Err != nil || r.New.Settings.WsInterval != 5 || r.New.Filter.Keywords[0] != "广告" {
+		t.Fatalf("unexpected reload: %+v", r)
+	}
+	if strings.Join(r.Changed, ",") != "settings.wsInterval,filter.keywords" || strings.Join(r.Restart, ",") != "fishPi.username" {
+		t.Errorf("unexpected diff: %v %v", r.Changed, r.Restart)
+	}
+
+	r = edit(`fishPi:
+  username: ""
+settings:
+  wsInterval: 6
+`)
+	if r.Err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	if w.current.Settings.WsInterval != 5 {
+		t.Errorf("previous config not kept: %d", w.current.Settings.WsInterval)
+	}
+	if body, _ := json.Marshal(r); strings.Contains(string(body), "key") {
+		t.Errorf("reload event leaks config: %s", body)
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed template.yml
+var template []byte
+
+// Create 基于带注释的模板生成配置文件 写入前会校验 已存在的文件会被备份
+// secrets为true时apiKey和密码MD5写入加密的密钥文件 {配置文件名}.secrets 否则apiKey以明文保存 不保存密码MD5
+// 二次验证码只能使用一次 不会保存
+func Create(path string, f *FishPi, secrets bool) (*Config, error) {
+	c := &Config{path: path, raw: new(yaml.Node)}
+	if err := yaml.Unmarshal(template, c.raw); err != nil {
+		return nil, err
+	}
+	c.setRaw("fishPi", "apiBase", f.ApiBase)
+	c.setRaw("fishPi", "username", f.Username)
+	if secrets {
+		c.setRaw("secrets", "file", filepath.Base(path)+".secrets")
+	} else {
+		c.setRaw("fishPi", "apiKey", f.ApiKey)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.raw); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(buf.Bytes(), c); err != nil {
+		return nil, err
+	}
+	c.setDefaults()
+	c.FishPi.ApiKey = f.ApiKey
+	if secrets {
+		c.FishPi.PasswordMd5 = f.PasswordMd5
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if secrets {
+		s, err := openSecrets(c.secretsPath(), true)
+		if err != nil {
+			return nil, err
+		}
+		s.set("fishPi.apiKey", c.FishPi.ApiKey)
+		if c.FishPi.PasswordMd5 != "" {
+			s.set("fishPi.passwordMd5", c.FishPi.PasswordMd5)
+		}
+		if err = s.save(); err != nil {
+			return nil, err
+		}
+		c.secrets = s
+	}
+	if err := writeFile(path, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const backupNum = 3 // 保留的备份数量 {path}.bak.1 为最近一次
+
+// setRaw 修改配置文件中的字段 保留注释、顺序和未知字段 不存在时追加
+func (c *Config) setRaw(section, key, value string) {
+	if c.raw == nil || len(c.raw.Content) == 0 {
+		c.raw = &yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+	root := c.raw.Content[0]
+
+	sectionNode := mappingValue(root, section)
+	if sectionNode == nil || sectionNode.Kind != yaml.MappingNode {
+		if sectionNode == nil {
+			sectionNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: section}, sectionNode)
+		} else {
+			// 空的配置段 例如只写了 `ice:`
+			sectionNode.Kind, sectionNode.Tag, sectionNode.Value = yaml.MappingNode, "!!map", ""
+		}
+	}
+
+	valueNode := mappingValue(sectionNode, key)
+	if valueNode == nil {
+		valueNode = &yaml.Node{Kind: yaml.ScalarNode, Style: yaml.DoubleQuotedStyle}
+		sectionNode.Content = append(sectionNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+	}
+	valueNode.Kind, valueNode.Tag, valueNode.Value = yaml.ScalarNode, "!!str", value
+}
+
+// removeRaw 从配置文件中删除字段 返回原来的值
+func (c *Config) removeRaw(section, key string) (string, bool) {
+	if c.raw == nil || len(c.raw.Content) == 0 {
+		return "", false
+	}
+	sectionNode := mappingValue(c.raw.Content[0], section)
+	if sectionNode == nil || sectionNode.Kind != yaml.MappingNode {
+		return "", false
+	}
+	for i := 0; i+1 < len(sectionNode.Content); i += 2 {
+		if sectionNode.Content[i].Value == key {
+			value := sectionNode.Content[i+1].Value
+			sectionNode.Content = append(sectionNode.Content[:i], sectionNode.Content[i+2:]...)
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func (c *Config) save() error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.raw); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return writeFile(c.path, buf.Bytes())
+}
+
+// writeFile 先写入同目录的临时文件再重命名 避免写入中断导致配置文件损坏 权限为0600
+func writeFile(path string, body []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err = tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(body); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = backup(path); err != nil {
+		return fmt.Errorf("备份配置文件失败：%w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// backup 轮转备份 {path}.bak.1 ~ {path}.bak.{backupNum}
+func backup(path string) error {
+	src, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	for i := backupNum - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.bak.%d", path, i), fmt.Sprintf("%s.bak.%d", path, i+1))
+	}
+
+	dst, err := os.OpenFile(path+".bak.1", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// resolve 相对路径基于配置文件所在目录
+func (c *Config) resolve(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(c.path), path)
+}
+
+// ArchivePath 聊天记录存档的实际路径 关闭存档时为空
+func (c *Config) ArchivePath() string {
+	if c.Archive == nil || c.Archive.Disable {
+		return ""
+	}
+	return c.resolve(c.Archive.Path)
+}
+
+// BlockPath 屏蔽规则文件的实际路径
+func (c *Config) BlockPath() string {
+	return c.resolve(c.Filter.BlockFile)
+}
+
+// LedgerPath 红包账本的实际路径
+func (c *Config) LedgerPath() string {
+	return c.resolve(c.RedPacket.Ledger)
+}
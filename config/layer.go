@@ -0,0 +1,219 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	envPrefix = "FISHPI_"
+	masked    = "******"
+)
+
+// Overrides 命令行参数覆盖的配置项 key为yaml路径 例如 fishPi.apiKey
+type Overrides map[string]string
+
+// field 一个可以被环境变量和命令行覆盖的配置项
+type field struct {
+	path   string // yaml路径 fishPi.apiKey
+	env    string // 环境变量 FISHPI_API_KEY
+	secret bool   // 打印时是否隐藏
+	value  reflect.Value
+}
+
+// RegisterFlags 为每个配置项注册命令行参数 例如 -fishPi.apiKey=xxx 只有显式传入的参数才会覆盖配置
+func RegisterFlags(fs *flag.FlagSet) Overrides {
+	o := make(Overrides)
+	for _, f := range new(Config).fields() {
+		path := f.path
+		fs.Func(path, fmt.Sprintf("覆盖配置项 %s 环境变量 %s", path, f.env), func(s string) error {
+			o[path] = s
+			return nil
+		})
+	}
+	return o
+}
+
+// Load 按照 默认值 < 配置文件 < 密钥文件 < 环境变量 < 命令行参数 的顺序加载配置 配置文件不存在时仅使用其余来源
+func Load(path string, overrides Overrides) (*Config, error) {
+	return load(path, overrides, nil)
+}
+
+// load prev不为空时为热加载 复用其已解密的密钥文件 避免再次输入口令
+func load(path string, overrides Overrides, prev *Config) (*Config, error) {
+	c := &Config{path: path}
+
+	body, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err = yaml.Unmarshal(body, c); err != nil {
+			return nil, err
+		}
+		c.raw = new(yaml.Node)
+		if err = yaml.Unmarshal(body, c.raw); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := c.fields()
+	overridden := make(map[string]bool)
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.env); ok {
+			if err = f.set(v); err != nil {
+				return nil, fmt.Errorf("环境变量 %s：%w", f.env, err)
+			}
+			overridden[f.path] = true
+		}
+	}
+	for _, f := range fields {
+		if v, ok := overrides[f.path]; ok {
+			if err = f.set(v); err != nil {
+				return nil, fmt.Errorf("命令行参数 -%s：%w", f.path, err)
+			}
+			overridden[f.path] = true
+		}
+	}
+
+	// 密钥文件路径本身可以被环境变量和命令行参数修改 所以最后读取
+	if err = c.loadSecrets(fields, overridden, prev); err != nil {
+		return nil, err
+	}
+
+	c.setDefaults()
+	if err = c.Validate(); err != nil {
+		return nil, err
+	}
+	c.FishPi.Init()
+
+	return c, nil
+}
+
+// Masked 生效的配置 敏感字段已隐藏
+func (c *Config) Masked() string {
+	var sb strings.Builder
+	section := ""
+	for _, f := range c.fields() {
+		name, key, _ := strings.Cut(f.path, ".")
+		if name != section {
+			section = name
+			sb.WriteString(name + ":\n")
+		}
+		value := fmt.Sprint(f.value.Interface())
+		switch f.value.Kind() {
+		case reflect.Slice:
+			value = strconv.Quote(strings.Join(f.value.Interface().([]string), ","))
+		case reflect.String:
+			if f.secret && value != "" {
+				value = masked
+			}
+			value = strconv.Quote(value)
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %s # %s\n", key, value, f.env))
+	}
+	return sb.String()
+}
+
+// fields 遍历所有配置项 为空的配置段会被初始化
+func (c *Config) fields() []field {
+	var fields []field
+	cv := reflect.ValueOf(c).Elem()
+	ct := cv.Type()
+	for i := 0; i < ct.NumField(); i++ {
+		sf := ct.Field(i)
+		section := yamlName(sf)
+		if section == "" || sf.Type.Kind() != reflect.Ptr || sf.Type.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		sv := cv.Field(i)
+		if sv.IsNil() {
+			sv.Set(reflect.New(sf.Type.Elem()))
+		}
+		sv = sv.Elem()
+		for j := 0; j < sv.NumField(); j++ {
+			ff := sv.Type().Field(j)
+			name := yamlName(ff)
+			if name == "" || !settable(ff.Type) {
+				continue
+			}
+			env := envPrefix + snake(name)
+			if section != "fishPi" {
+				env = envPrefix + snake(section) + "_" + snake(name)
+			}
+			fields = append(fields, field{
+				path:   section + "." + name,
+				env:    env,
+				secret: ff.Tag.Get("secret") == "true",
+				value:  sv.Field(j),
+			})
+		}
+	}
+	return fields
+}
+
+func (f *field) set(s string) error {
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case reflect.Slice:
+		var list []string
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+		f.value.Set(reflect.ValueOf(list))
+	}
+	return nil
+}
+
+func settable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Bool:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	default:
+		return false
+	}
+}
+
+func yamlName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// snake apiKey -> API_KEY
+func snake(s string) string {
+	var sb strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) && i > 0 {
+			sb.WriteByte('_')
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	// PassphraseEnv 密钥文件口令 未设置时在终端中输入
+	PassphraseEnv = "FISHPI_SECRETS_PASSPHRASE"
+
+	secretsVersion = 1
+	secretsIter    = 600000
+	secretsKeyLen  = 32
+	secretsSaltLen = 16
+)
+
+// ErrPassphrase 口令错误或者密钥文件已损坏
+var ErrPassphrase = errors.New("密钥文件口令错误或者文件已损坏")
+
+// errNoPrompt 启动完成后终端由会话使用 不能再输入口令
+var errNoPrompt = fmt.Errorf("运行中无法输入密钥文件口令 请设置环境变量 %s 或者重启", PassphraseEnv)
+
+// Secrets 加密的密钥文件 配置后敏感字段从该文件读取和更新
+type Secrets struct {
+	File string `yaml:"file"` // 密钥文件路径 相对路径基于配置文件所在目录 为空则不使用
+}
+
+// secretFile 密钥文件的存储格式 内容为 PBKDF2-SHA256 派生密钥的 AES-256-GCM 密文
+type secretFile struct {
+	Version int    `json:"version"`
+	Iter    int    `json:"iter"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// secretStore 解密后的密钥文件 key为yaml路径 例如 fishPi.apiKey
+type secretStore struct {
+	path   string
+	salt   []byte
+	iter   int
+	key    []byte
+	values map[string]string
+}
+
+// openSecrets 解密密钥文件 文件不存在时创建空的密钥文件 需要输入两次口令
+// prompt为false时只从环境变量读取口令 解密后的密钥保存在secretStore中 之后不再需要口令
+func openSecrets(path string, prompt bool) (*secretStore, error) {
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return newSecrets(path, prompt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f secretFile
+	if err = json.Unmarshal(body, &f); err != nil {
+		return nil, fmt.Errorf("密钥文件格式错误 %s：%w", path, err)
+	}
+	if f.Version != secretsVersion {
+		return nil, fmt.Errorf("不支持的密钥文件版本：%d", f.Version)
+	}
+
+	passphrase, err := readPassphrase(fmt.Sprintf("请输入密钥文件口令 %s：", path), false, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &secretStore{path: path, salt: f.Salt, iter: f.Iter, values: make(map[string]string)}
+	if s.key, err = pbkdf2.Key(sha256.New, passphrase, s.salt, s.iter, secretsKeyLen); err != nil {
+		return nil, err
+	}
+	gcm, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+	if err = json.Unmarshal(plain, &s.values); err != nil {
+		return nil, fmt.Errorf("密钥文件内容错误：%w", err)
+	}
+	return s, nil
+}
+
+func newSecrets(path string, prompt bool) (*secretStore, error) {
+	passphrase, err := readPassphrase(fmt.Sprintf("请设置密钥文件口令 %s：", path), true, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &secretStore{path: path, salt: make([]byte, secretsSaltLen), iter: secretsIter, values: make(map[string]string)}
+	if _, err = rand.Read(s.salt); err != nil {
+		return nil, err
+	}
+	if s.key, err = pbkdf2.Key(sha256.New, passphrase, s.salt, s.iter, secretsKeyLen); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *secretStore) get(key string) (string, bool) {
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *secretStore) set(key, value string) {
+	s.values[key] = value
+}
+
+// save 每次保存都使用新的nonce
+func (s *secretStore) save() error {
+	plain, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	gcm, err := s.gcm()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(&secretFile{
+		Version: secretsVersion,
+		Iter:    s.iter,
+		Salt:    s.salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, plain, nil),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(s.path, body)
+}
+
+func (s *secretStore) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readPassphrase 优先读取环境变量 否则在终端中输入 confirm为true时需要输入两次 interactive为false时不在终端中输入
+func readPassphrase(prompt string, confirm, interactive bool) (string, error) {
+	if v, ok := os.LookupEnv(PassphraseEnv); ok {
+		if v == "" {
+			return "", fmt.Errorf("环境变量 %s 为空", PassphraseEnv)
+		}
+		return v, nil
+	}
+	if !interactive {
+		return "", errNoPrompt
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("无法读取密钥文件口令 请设置环境变量 %s", PassphraseEnv)
+	}
+
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+	passphrase, err := read(prompt)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("口令不能为空")
+	}
+	if confirm {
+		again, err := read("请再次输入口令：")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("两次输入的口令不一致")
+		}
+	}
+	return passphrase, nil
+}
+
+// secretsPath 密钥文件的实际路径
+func (c *Config) secretsPath() string {
+	if c.Secrets == nil || c.Secrets.File == "" {
+		return ""
+	}
+	return c.resolve(c.Secrets.File)
+}
+
+// loadSecrets 密钥文件的内容覆盖配置文件 但不覆盖环境变量和命令行参数 密钥文件尚未创建时跳过
+// 热加载时复用已解密的密钥文件 在配置监听协程中运行 不会在终端中输入口令
+func (c *Config) loadSecrets(fields []field, overridden map[string]bool, prev *Config) error {
+	path := c.secretsPath()
+	if prev != nil {
+		if prev.secretsPath() != path {
+			return errors.New("secrets.file 修改后需要重启")
+		}
+		c.secrets = prev.secrets
+	}
+	if path == "" {
+		return nil
+	}
+
+	s := c.secrets
+	if s == nil {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+		var err error
+		if s, err = openSecrets(path, prev == nil); err != nil {
+			return err
+		}
+		c.secrets = s
+	}
+
+	for _, f := range fields {
+		if !f.secret || overridden[f.path] {
+			continue
+		}
+		if v, ok := s.get(f.path); ok {
+			if err := f.set(v); err != nil {
+				return fmt.Errorf("密钥文件 %s：%w", f.path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DisablePrompt 终端交给会话之前调用 之后需要口令时只读取环境变量 否则返回错误 避免和终端输入争抢
+func (c *Config) DisablePrompt() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.noPrompt = true
+}
+
+// update 更新配置项并保存 配置了密钥文件时敏感字段写入密钥文件
+func (c *Config) update(section, key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := section + "." + key
+	if c.secretsPath() != "" && c.isSecret(path) {
+		if c.secrets == nil {
+			s, err := openSecrets(c.secretsPath(), !c.noPrompt)
+			if err != nil {
+				return err
+			}
+			c.secrets = s
+		}
+		c.secrets.set(path, value)
+		return c.secrets.save()
+	}
+
+	c.setRaw(section, key, value)
+	return c.save()
+}
+
+func (c *Config) isSecret(path string) bool {
+	for _, f := range c.fields() {
+		if f.path == path {
+			return f.secret
+		}
+	}
+	return false
+}
+
+// MigrateSecrets 把配置文件中的明文敏感字段移动到密钥文件 未配置secrets.file时使用 {配置文件名}.secrets
+func (c *Config) MigrateSecrets() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.secretsPath() == "" {
+		c.Secrets.File = filepath.Base(c.path) + ".secrets"
+		c.setRaw("secrets", "file", c.Secrets.File)
+	}
+	if c.secrets == nil {
+		s, err := openSecrets(c.secretsPath(), !c.noPrompt)
+		if err != nil {
+			return 0, err
+		}
+		c.secrets = s
+	}
+
+	n := 0
+	for _, f := range c.fields() {
+		if !f.secret {
+			continue
+		}
+		section, key, _ := strings.Cut(f.path, ".")
+		if v, ok := c.removeRaw(section, key); ok && v != "" {
+			c.secrets.set(f.path, v)
+			n++
+		}
+	}
+
+	// 先保存密钥文件 避免明文字段被删除后丢失
+	if err := c.secrets.save(); err != nil {
+		return 0, err
+	}
+	return n, c.save()
+}
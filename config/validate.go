@@ -0,0 +1,233 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultApiBase     = "https://fishpi.cn"
+	MirrorApiBase      = "https://gaypi.cn" // 备用域名
+	DefaultUserAgent   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/103.0.0.0 Safari/537.36"
+	defaultWsInterval  = 3
+	defaultMsgCacheNum = 20
+	defaultIceUrl      = "wss://game.yuis.cc/wss"
+	defaultLogLevel    = "info"
+	defaultLogMaxSize  = 5
+	defaultArchivePath = "archive.db"
+	defaultBlockFile   = "blocks.json"
+	defaultLedgerFile  = "redpackets.jsonl"
+)
+
+var md5Pattern = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// ValidationError 配置校验错误 包含所有缺失或者不合法的字段
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("配置校验失败：\n - %s", strings.Join(e.Problems, "\n - "))
+}
+
+func (e *ValidationError) add(format string, a ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, a...))
+}
+
+// setDefaults 填充可选字段的默认值
+func (c *Config) setDefaults() {
+	if c.FishPi == nil {
+		c.FishPi = new(FishPi)
+	}
+	if c.FishPi.ApiBase == "" {
+		c.FishPi.ApiBase = DefaultApiBase
+	}
+	if c.FishPi.UserAgent == "" {
+		c.FishPi.UserAgent = DefaultUserAgent
+	}
+
+	if c.Settings == nil {
+		c.Settings = new(Settings)
+	}
+	if c.Settings.WsInterval == 0 {
+		c.Settings.WsInterval = defaultWsInterval
+	}
+	if c.Settings.MsgCacheNum == 0 {
+		c.Settings.MsgCacheNum = defaultMsgCacheNum
+	}
+
+	if c.Ice == nil {
+		c.Ice = new(Ice)
+	}
+	if c.Ice.Url == "" {
+		c.Ice.Url = defaultIceUrl
+	}
+
+	if c.Elves == nil {
+		c.Elves = new(Elves)
+	}
+	if c.Bridge == nil {
+		c.Bridge = new(Bridge)
+	}
+	if c.Filter == nil {
+		c.Filter = new(Filter)
+	}
+	if c.Filter.BlockFile == "" {
+		c.Filter.BlockFile = defaultBlockFile
+	}
+	if c.Archive == nil {
+		c.Archive = new(Archive)
+	}
+	if c.Archive.Path == "" {
+		c.Archive.Path = defaultArchivePath
+	}
+	if c.Notify == nil {
+		c.Notify = new(Notify)
+	}
+	if c.RedPacket == nil {
+		c.RedPacket = new(RedPacket)
+	}
+	if c.RedPacket.Ledger == "" {
+		c.RedPacket.Ledger = defaultLedgerFile
+	}
+	if c.Transform == nil {
+		c.Transform = new(Transform)
+	}
+	if c.Log == nil {
+		c.Log = new(Log)
+	}
+	if c.Log.Level == "" {
+		c.Log.Level = defaultLogLevel
+	}
+	if c.Log.MaxSize == 0 {
+		c.Log.MaxSize = defaultLogMaxSize
+	}
+	if c.Secrets == nil {
+		c.Secrets = new(Secrets)
+	}
+}
+
+// Validate 校验配置 一次性返回所有问题
+func (c *Config) Validate() error {
+	e := new(ValidationError)
+
+	// 配置文件中可以没有fishPi 由环境变量和命令行参数提供 setDefaults会补全为空的配置段
+	f := c.FishPi
+	validateUrl(e, "fishPi.apiBase", f.ApiBase, "http", "https")
+	if f.Username == "" {
+		e.add("fishPi.username 不能为空")
+	}
+	if f.ApiKey == "" && f.Password == "" && f.PasswordMd5 == "" {
+		e.add("fishPi.apiKey 和 fishPi.password/fishPi.passwordMd5 至少需要填写一个")
+	}
+	if f.PasswordMd5 != "" && f.Password == "" && !md5Pattern.MatchString(f.PasswordMd5) {
+		e.add("fishPi.passwordMd5 应当是32位的MD5值")
+	}
+
+	if s := c.Settings; s != nil {
+		if s.WsInterval < 0 {
+			e.add("settings.wsInterval 不能小于0：%d", s.WsInterval)
+		}
+		if s.MsgCacheNum < 0 {
+			e.add("settings.msgCacheNum 不能小于0：%d", s.MsgCacheNum)
+		}
+	}
+
+	if c.Ice != nil && c.Ice.Url != "" {
+		validateUrl(e, "ice.url", c.Ice.Url, "ws", "wss")
+	}
+
+	if l := c.Log; l != nil && (l.MaxSize < 0 || l.MaxBackups < 0 || l.MaxAge < 0) {
+		e.add("log.maxSize log.maxBackups log.maxAge 不能小于0")
+	}
+
+	if c.Archive != nil && c.Archive.RetentionDays < 0 {
+		e.add("archive.retentionDays 不能小于0：%d", c.Archive.RetentionDays)
+	}
+
+	if n := c.Notify; n != nil {
+		if n.Webhook != "" {
+			validateUrl(e, "notify.webhook", n.Webhook, "http", "https")
+		}
+		if n.RateLimit < 0 {
+			e.add("notify.rateLimit 不能小于0：%d", n.RateLimit)
+		}
+	}
+
+	if t := c.Transform; t != nil && t.KaibaiUrl != "" {
+		validateUrl(e, "transform.kaibaiUrl", t.KaibaiUrl, "http", "https")
+	}
+
+	if c.Bridge != nil && c.Bridge.Addr != "" {
+		if path, ok := strings.CutPrefix(c.Bridge.Addr, "unix:"); ok {
+			if path == "" {
+				e.add("bridge.addr unix socket路径不能为空")
+			}
+		} else if host, _, err := net.SplitHostPort(c.Bridge.Addr); err != nil {
+			e.add("bridge.addr 格式错误 %s：%s", c.Bridge.Addr, err)
+		} else if c.Bridge.Token == "" && !isLoopback(host) {
+			// 没有令牌时任何人都可以订阅聊天和私聊事件 只能监听本机地址
+			e.add("bridge.addr 未配置bridge.token时只能监听本机地址 例如127.0.0.1：%s", c.Bridge.Addr)
+		}
+	}
+
+	validatorsMu.Lock()
+	for _, v := range validators {
+		e.Problems = append(e.Problems, v(c)...)
+	}
+	validatorsMu.Unlock()
+
+	if len(e.Problems) != 0 {
+		return e
+	}
+	return nil
+}
+
+// Validator 使用配置的功能提供的校验 例如提醒方式和红包规则 返回的问题和配置本身的问题一起报告
+type Validator func(c *Config) []string
+
+var (
+	validatorsMu sync.Mutex
+	validators   []Validator
+)
+
+// RegisterValidator 注册功能提供的校验 需要在读取配置之前注册 热加载时同样生效
+func RegisterValidator(v Validator) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators = append(validators, v)
+}
+
+// isLoopback host是否只能从本机访问 为空时监听所有地址
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func validateUrl(e *ValidationError, field, value string, schemes ...string) {
+	if value == "" {
+		e.add("%s 不能为空", field)
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		e.add("%s 解析失败 %s：%s", field, value, err)
+		return
+	}
+	if u.Host == "" {
+		e.add("%s 缺少域名：%s", field, value)
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return
+		}
+	}
+	e.add("%s 协议应当是 %s：%s", field, strings.Join(schemes, "/"), value)
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+)
+
+// ConfigReload 配置文件重新加载 发布在会话的总线上
+const ConfigReload = "config-reload"
+
+var TopicReload = eventHandler.NewTopic[*Reload](ConfigReload)
+
+// reloadable 修改后可以直接生效的配置段 其余配置需要重启
+var reloadable = []string{"settings.", "filter.blockUsers", "filter.keywords", "notify.", "redPacket.auto", "redPacket.rules", "transform.", "log.level"}
+
+// Reload 一次重新加载的结果 Err不为空时配置没有变化
+type Reload struct {
+	Old     *Config
+	New     *Config
+	Changed []string // 已经生效的配置项
+	Restart []string // 需要重启才能生效的配置项
+	Err     error
+}
+
+// MarshalJSON 只输出修改的字段名 避免通过bridge泄露敏感配置
+func (r *Reload) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Changed []string `json:"changed,omitempty"`
+		Restart []string `json:"restart,omitempty"`
+		Err     string   `json:"error,omitempty"`
+	}{Changed: r.Changed, Restart: r.Restart}
+	if r.Err != nil {
+		v.Err = r.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// Watcher 定时检查配置文件 修改后重新加载 校验失败时继续使用之前的配置
+type Watcher struct {
+	conf      *Config // 启动时加载的配置 负责保存和持有密钥文件
+	current   *Config
+	overrides Overrides
+	interval  time.Duration
+
+	modTime time.Time
+	size    int64
+
+	bus    *eventHandler.Bus
+	logger logger.Logger
+}
+
+func NewWatcher(conf *Config, overrides Overrides, interval time.Duration, bus *eventHandler.Bus, logger logger.Logger) *Watcher {
+	w := &Watcher{
+		conf:      conf,
+		current:   conf,
+		overrides: overrides,
+		interval:  interval,
+		bus:       bus,
+		logger:    logger.Named("config"),
+	}
+	w.modTime, w.size = w.stat()
+	return w
+}
+
+func (w *Watcher) Name() string {
+	return "config"
+}
+
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.check()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// check 配置文件的修改时间或者大小变化时重新加载
+func (w *Watcher) check() {
+	modTime, size := w.stat()
+	if modTime.Equal(w.modTime) && size == w.size {
+		return
+	}
+	w.modTime, w.size = modTime, size
+	w.reload()
+}
+
+func (w *Watcher) stat() (time.Time, int64) {
+	info, err := os.Stat(w.conf.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+func (w *Watcher) reload() {
+	next, err := load(w.conf.path, w.overrides, w.conf)
+	if err != nil {
+		w.logger.Error("配置文件重新加载失败 继续使用之前的配置", "err", err)
+		eventHandler.Publish(w.bus, TopicReload, &Reload{Old: w.current, New: w.current, Err: err})
+		return
+	}
+
+	// 之后的保存基于新的文件内容 避免覆盖这次的修改
+	w.conf.mu.Lock()
+	w.conf.raw = next.raw
+	w.conf.mu.Unlock()
+
+	r := &Reload{Old: w.current, New: next}
+	r.Changed, r.Restart = diff(w.current, next)
+	w.current = next
+	if len(r.Changed) == 0 && len(r.Restart) == 0 {
+		return
+	}
+
+	if len(r.Changed) != 0 {
+		w.logger.Info("配置已更新", "changed", strings.Join(r.Changed, ","))
+	}
+	if len(r.Restart) != 0 {
+		w.logger.Warn("以下配置需要重启后生效", "restart", strings.Join(r.Restart, ","))
+	}
+	eventHandler.Publish(w.bus, TopicReload, r)
+}
+
+// diff 比较两份配置 返回可以直接生效和需要重启的配置项
+func diff(old, next *Config) (changed, restart []string) {
+	// 空列表和未填写视为相同
+	values := make(map[string]string)
+	for _, f := range old.fields() {
+		values[f.path] = fmt.Sprint(f.value.Interface())
+	}
+	for _, f := range next.fields() {
+		if values[f.path] == fmt.Sprint(f.value.Interface()) {
+			continue
+		}
+		if isReloadable(f.path) {
+			changed = append(changed, f.path)
+		} else {
+			restart = append(restart, f.path)
+		}
+	}
+	return
+}
+
+func isReloadable(path string) bool {
+	for _, prefix := range reloadable {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -146,15 +146,30 @@ func (a *Api) wss() string {
 }
 
 // 私聊连接
-func (a *Api) chatChanel() string {
+func (a *Api) chatChanel(apiKey, toUser string) string {
 	u := *a.u
 	u.Path = "/chat-channel"
+	value := u.Query()
+	value.Add("apiKey", apiKey)
+	value.Add("toUser", toUser)
+	u.RawQuery = value.Encode()
 	return strings.ReplaceAll(u.String(), "https://", "wss://")
 }
 
-// 用户通知连接 需要cookie
-func (a *Api) userChannel() string {
+// 用户通知连接
+func (a *Api) userChannel(apiKey string) string {
 	u := *a.u
 	u.Path = "/user-channel"
+	value := u.Query()
+	value.Add("apiKey", apiKey)
+	u.RawQuery = value.Encode()
 	return strings.ReplaceAll(u.String(), "https://", "wss://")
 }
+
+// chatroomMessage 聊天室消息的网页地址 用于引用
+func (a *Api) chatroomMessage(oId string) *url.URL {
+	u := *a.u
+	u.Path = "/cr"
+	u.Fragment = "chatroom" + oId
+	return &u
+}
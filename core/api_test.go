@@ -4,6 +4,7 @@ import (
 	"fishpi/config"
 	"fishpi/logger"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 	"testing"
@@ -11,19 +12,19 @@ import (
 
 func TestApi(t *testing.T) {
 	// 初始化日志程序
-	loger := logger.NewConsoleLogger()
+	loger := logger.NewLogger(os.Stderr, slog.LevelWarn)
 
 	// 读取配置文件
 	conf, err := config.NewConfig(`../_tmp/config.yaml`)
 	if err != nil {
-		loger.Logf("读取配置文件失败 \n错误信息：%s", err)
+		t.Logf("读取配置文件失败 \n错误信息：%s", err)
 		return
 	}
 
 	// 初始化FishPi API
 	var api *Api
 	if api, err = NewApi(conf.FishPi.ApiBase); err != nil {
-		loger.Logf("FishPi地址信息填写失败 %s", err)
+		t.Logf("FishPi地址信息填写失败 %s", err)
 		return
 	}
 
@@ -89,37 +90,6 @@ func (c *comments) parse(reply *ArticleInfoReply) {
 	for _, v := range reply.Data.Article.ArticleComments {
 		if v.CommentOriginalCommentId != "" {
 			continue
-			has := false
-			for i, v2 := range *c {
-				if v2.OId == v.CommentOriginalCommentId {
-					(*c)[i].Children = append((*c)[i].Children, comment{
-						OId:       v.OId,
-						POId:      v.CommentAuthorId,
-						PStatus:   v.Commenter.UserStatus,
-						PUsername: v.Commenter.UserName,
-						PContent:  v.CommentContent,
-						PTime:     v.CommentCreateTime,
-					})
-					has = true
-					break
-				}
-			}
-			if !has {
-				*c = append(*c, comment{
-					OId: v.CommentOriginalCommentId,
-					Children: []comment{
-						{
-							OId:       v.OId,
-							POId:      v.CommentAuthorId,
-							PStatus:   v.Commenter.UserStatus,
-							PUsername: v.Commenter.UserName,
-							PContent:  v.CommentContent,
-							PTime:     v.CommentCreateTime,
-						},
-					},
-				})
-			}
-			continue
 		}
 		if v.Commenter.UserStatus == 4 {
 			continue
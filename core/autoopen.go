@@ -0,0 +1,167 @@
+package core
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"fishpi/logger"
+	"fishpi/redpacket"
+)
+
+// autoOpenHistory 记住最近尝试过的红包数量 避免重复打开
+const autoOpenHistory = 200
+
+// AutoOpen 按规则自动打开红包 订阅 TopicRedPacket 和 TopicRedPacketStatus 每次判断和结果都会记录日志
+type AutoOpen struct {
+	mu      sync.Mutex
+	enabled bool
+	rules   map[string]*redpacket.Rule
+	seen    map[string]bool
+	order   []string
+	waiting map[string]*redpacket.Packet // 等待打开的红包 根据领取消息更新
+
+	after   func(time.Duration, func()) // 便于测试替换
+	sdk     *Sdk
+	display logger.Display
+	logger  logger.Logger
+}
+
+func NewAutoOpen(sdk *Sdk, display logger.Display, logger logger.Logger) *AutoOpen {
+	return &AutoOpen{
+		seen:    make(map[string]bool),
+		waiting: make(map[string]*redpacket.Packet),
+		after:   func(d time.Duration, f func()) { time.AfterFunc(d, f) },
+		sdk:     sdk,
+		display: display,
+		logger:  logger.Named("auto-open"),
+	}
+}
+
+// SetDisplay 更换展示结果的位置 例如simple模式的信息框
+func (a *AutoOpen) SetDisplay(display logger.Display) *AutoOpen {
+	a.display = display
+	return a
+}
+
+// Update 更新是否开启和规则 规则错误时保持之前的配置
+func (a *AutoOpen) Update(enabled bool, list []string) error {
+	rules, err := redpacket.ParseRules(list)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enabled, a.rules = enabled, rules
+	a.logger.Info("自动抢红包配置已更新", "enabled", enabled, "rules", len(rules))
+	return nil
+}
+
+// HandleRedPacket 判断是否打开红包 满足规则时随机等待后打开
+func (a *AutoOpen) HandleRedPacket(msg *WsMsgReply) {
+	p := newPacket(msg)
+
+	a.mu.Lock()
+	if !a.enabled || a.seen[p.OId] {
+		a.mu.Unlock()
+		return
+	}
+	a.remember(p.OId)
+	rule := a.rules[p.Type]
+	a.mu.Unlock()
+
+	ok, reason := redpacket.Decide(rule, p, a.sdk.username)
+	if !ok {
+		a.logger.Info("跳过红包", "oId", p.OId, "type", p.Type, "sender", p.Sender, "reason", reason)
+		return
+	}
+	delay := rule.Delay()
+	a.logger.Info("准备打开红包", "oId", p.OId, "type", p.Type, "sender", p.Sender, "delay", delay)
+	a.mu.Lock()
+	a.waiting[p.OId] = p
+	a.mu.Unlock()
+	a.after(delay, func() { a.open(p.OId) })
+}
+
+// HandleStatus 有人领取了红包 更新等待打开的红包的领取数量
+func (a *AutoOpen) HandleStatus(msg *WsMsgReply) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if p, ok := a.waiting[msg.OId]; ok {
+		p.Got, p.Count = msg.Got, msg.Count
+	}
+}
+
+// open 等待结束后按最新的领取情况和规则再判断一次 心跳红包的领取者在等待期间会变化
+func (a *AutoOpen) open(oId string) {
+	a.mu.Lock()
+	p := a.waiting[oId]
+	delete(a.waiting, oId)
+	enabled := a.enabled
+	rule := a.rules[p.Type]
+	a.mu.Unlock()
+
+	if !enabled {
+		a.logger.Info("跳过红包", "oId", p.OId, "type", p.Type, "sender", p.Sender, "reason", "自动抢红包已关闭")
+		return
+	}
+	if rule != nil && (p.Type == redpacket.TypeHeartbeat || rule.MinPerSlot > 0) {
+		a.refresh(p)
+	}
+	if ok, reason := redpacket.Decide(rule, p, a.sdk.username); !ok {
+		a.logger.Info("跳过红包", "oId", p.OId, "type", p.Type, "sender", p.Sender, "reason", reason)
+		return
+	}
+
+	result, err := a.sdk.OpenRedPacketResult(p.OId, p.Type, rule.Gesture)
+	if err != nil {
+		a.logger.Warn("自动打开红包失败", "oId", p.OId, "type", p.Type, "err", err)
+		a.display.Printf("[自动抢红包] 打开%s的红包失败 %s", p.Sender, err)
+		return
+	}
+	a.logger.Info("自动打开红包", "oId", p.OId, "type", p.Type, "sender", p.Sender, "received", result.Received, "gain", result.Gain)
+	a.display.Print("[自动抢红包] " + result.String())
+}
+
+// refresh 从最近的聊天记录中获取红包当前的领取者 领取消息中没有领取的积分 获取失败时使用已有的信息
+func (a *AutoOpen) refresh(p *redpacket.Packet) {
+	msg, err := a.sdk.recentRedPacket(p.OId)
+	if err != nil {
+		a.logger.Warn("获取红包领取情况失败", "oId", p.OId, "err", err)
+		return
+	}
+	if msg != nil {
+		latest := newPacket(msg)
+		p.Got, p.Count, p.Who = max(p.Got, latest.Got), latest.Count, latest.Who
+	}
+}
+
+// remember 调用时需要持有锁
+func (a *AutoOpen) remember(oId string) {
+	a.seen[oId] = true
+	a.order = append(a.order, oId)
+	if len(a.order) > autoOpenHistory {
+		delete(a.seen, a.order[0])
+		a.order = a.order[1:]
+	}
+}
+
+// newPacket 把红包消息转换为规则判断需要的信息
+func newPacket(msg *WsMsgReply) *redpacket.Packet {
+	rp := msg.JsonInfo
+	p := &redpacket.Packet{
+		OId:       msg.OId,
+		Type:      rp.Type,
+		Sender:    msg.UserName,
+		Receivers: rp.Recivers,
+		Money:     rp.Money,
+		Count:     rp.Count,
+		Got:       rp.Got,
+	}
+	if len(rp.Who) != 0 {
+		if body, err := json.Marshal(rp.Who); err == nil {
+			_ = json.Unmarshal(body, &p.Who)
+		}
+	}
+	return p
+}
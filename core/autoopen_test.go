@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fishpi/logger"
+)
+
+func TestAutoOpenRecheck(t *testing.T) {
+	who := []map[string]any{}
+	opened := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chat-room/more":
+			content, _ := json.Marshal(map[string]any{"msgType": "redPacket", "type": RedPacketTypeHeartbeat, "money": 100, "count": 5, "got": len(who), "who": who})
+			body, _ := json.Marshal(map[string]any{"code": 0, "data": []map[string]any{{"oId": "1", "userName": "alice", "content": string(content)}}})
+			w.Write(body)
+		case "/chat-room/red-packet/open":
+			opened++
+			fmt.Fprint(w, `{"code":0,"who":[{"userName":"me","userMoney":10}],"info":{"count":5,"got":1,"msg":"","userName":"alice"}}`)
+		}
+	}))
+	defer srv.Close()
+
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	api, _ := NewApi(srv.URL)
+	sdk := NewSdk(api, "test", "key", "me", l)
+
+	var pending []func()
+	a := NewAutoOpen(sdk, new(testDisplay), l)
+	a.after = func(_ time.Duration, f func()) { pending = append(pending, f) }
+	if err := a.Update(true, []string{"heartbeat maxRisk:0.3", "random"}); err != nil {
+		t.Fatal(err)
+	}
+
+	packet := func(oId, typ string) *WsMsgReply {
+		return &WsMsgReply{Type: WsMsgTypeMsg, OId: oId, UserName: "alice", JsonInfo: &JsonInfo{MsgType: JsonMsgTypeRedPacket, Type: typ, Money: 100, Count: 5}}
+	}
+
+	// 收到时还没有人领取 等待期间领取者中亏损的比例超过了maxRisk
+	a.HandleRedPacket(packet("1", RedPacketTypeHeartbeat))
+	if len(pending) != 1 {
+		t.Fatalf("heartbeat packet not scheduled")
+	}
+	who = append(who, map[string]any{"userName": "bob", "userMoney": -20}, map[string]any{"userName": "carol", "userMoney": 30})
+	pending[0]()
+	if opened != 0 {
+		t.Errorf("心跳红包风险超过maxRisk时不应当打开")
+	}
+
+	// 等待期间被领完
+	a.HandleRedPacket(packet("2", RedPacketTypeRandom))
+	a.HandleStatus(&WsMsgReply{Type: WsMsgTypeRedPacketStatus, OId: "2", Count: 5, Got: 5})
+	pending[1]()
+	if opened != 0 {
+		t.Errorf("已经领完的红包不应当打开")
+	}
+
+	a.HandleRedPacket(packet("3", RedPacketTypeRandom))
+	pending[2]()
+	if opened != 1 {
+		t.Errorf("opened %d", opened)
+	}
+}
@@ -0,0 +1,41 @@
+package core
+
+import "sync"
+
+// msgCache 最近收到的聊天消息 接收消息和执行指令在不同的goroutine中 需要加锁
+type msgCache struct {
+	mu   sync.Mutex
+	list []*WsMsgReply
+}
+
+// add 超过size条时丢弃最早的消息
+func (c *msgCache) add(msg *WsMsgReply, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list = append(c.list, msg)
+	if len(c.list) >= size {
+		c.list = c.list[len(c.list)-size:]
+	}
+}
+
+// latest 最近的一条消息 没有时返回nil
+func (c *msgCache) latest() *WsMsgReply {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.list) == 0 {
+		return nil
+	}
+	return c.list[len(c.list)-1]
+}
+
+// find 按oId查找消息 没有时返回nil
+func (c *msgCache) find(oId string) *WsMsgReply {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.list) - 1; i >= 0; i-- {
+		if c.list[i].OId == oId {
+			return c.list[i]
+		}
+	}
+	return nil
+}
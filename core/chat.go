@@ -0,0 +1,74 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+)
+
+// ChatHandler 私聊消息处理
+type ChatHandler struct {
+	toUser string
+
+	eh      *eventHandler.Bus
+	display logger.Display
+	logger  logger.Logger
+}
+
+func NewChatHandler(toUser string, eh *eventHandler.Bus, display logger.Display, logger logger.Logger) *ChatHandler {
+	return &ChatHandler{
+		toUser:  toUser,
+		eh:      eh,
+		display: display,
+		logger:  logger.Named("chat").With("toUser", toUser),
+	}
+}
+
+func (c *ChatHandler) HandleMsg(bytes []byte) {
+	msg := &ChatChannelMsg{}
+	if err := json.Unmarshal(bytes, msg); err != nil {
+		c.logger.Warn("parse chat message failed", "err", err, "body", string(bytes))
+		return
+	}
+	c.display.Print(msg.Msg())
+}
+
+func (c *ChatHandler) HandleWsStatusMsg(state eventHandler.ConnState) {
+	c.display.Print(state.String())
+}
+
+// HandleInput 发送私聊消息
+func (c *ChatHandler) HandleInput(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	eventHandler.Publish(c.eh, eventHandler.TopicWsSend, []byte(line))
+}
+
+// UserChannelHandler 用户通知处理
+type UserChannelHandler struct {
+	display logger.Display
+	logger  logger.Logger
+}
+
+func NewUserChannelHandler(display logger.Display, logger logger.Logger) *UserChannelHandler {
+	return &UserChannelHandler{display: display, logger: logger.Named("user")}
+}
+
+func (u *UserChannelHandler) HandleMsg(bytes []byte) {
+	msg := &UserChannelMsg{}
+	if err := json.Unmarshal(bytes, msg); err != nil {
+		u.logger.Warn("parse user channel message failed", "err", err, "body", string(bytes))
+		return
+	}
+	if content := msg.Msg(); content != "" {
+		u.display.Print(content)
+	}
+}
+
+func (u *UserChannelHandler) HandleWsStatusMsg(state eventHandler.ConnState) {
+	u.display.Print(state.String())
+}
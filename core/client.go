@@ -1,176 +1,112 @@
 package core
 
 import (
-	"bufio"
-	"fishpi/eventHandler"
 	"fmt"
-	"log"
-	"os"
 	"strings"
 
+	"fishpi/archive"
+	"fishpi/block"
+	"fishpi/command"
+	"fishpi/eventHandler"
 	"fishpi/logger"
 )
 
 type Client struct {
-	sdk *Sdk
-	ln  *lnClient
+	sdk      *Sdk
+	ln       *lnClient
+	archive  *archive.Archive // 聊天记录存档 未开启时为nil
+	blocks   *block.List      // 屏蔽规则 未开启时为nil
+	env      *commandEnv
+	commands *command.Registry // 终端指令
 
-	eh     eventHandler.EventHandler
-	logger logger.Logger
+	eh      *eventHandler.Bus
+	display logger.Display
+	logger  logger.Logger
 }
 
-func NewClient(sdk *Sdk, eh eventHandler.EventHandler, logger logger.Logger) *Client {
+func NewClient(sdk *Sdk, eh *eventHandler.Bus, display logger.Display, logger logger.Logger) *Client {
 	c := &Client{
-		sdk:    sdk,
-		eh:     eh,
-		logger: logger,
+		sdk:     sdk,
+		eh:      eh,
+		display: display,
+		logger:  logger.Named("client"),
 	}
+	c.env = &commandEnv{sdk: sdk, eh: eh, archive: func() *archive.Archive { return c.archive }, blocks: func() *block.List { return c.blocks }, said: c.said}
+	c.commands = command.NewRegistry()
+	c.env.register(c.commands)
+	// 发送端旧的写法topic-{新话题}发布新话题
+	c.commands.Register(&command.Command{
+		Name:    "topic-",
+		Usage:   "{新话题}",
+		Help:    "同topic {新话题}",
+		MinArgs: 1,
+		Run: func(ctx *command.Context) error {
+			return c.env.send(fmt.Sprintf("[setdiscuss]%s[/setdiscuss]", ctx.Raw))
+		},
+	})
 
 	return c
 }
 
-func (c *Client) SendMode() {
+// SetArchive 设置聊天记录存档 用于search指令
+func (c *Client) SetArchive(arc *archive.Archive) *Client {
+	c.archive = arc
+	return c
+}
+
+// SetBlocks 设置屏蔽规则 用于block指令
+func (c *Client) SetBlocks(l *block.List) *Client {
+	c.blocks = l
+	return c
+}
+
+// said 发送消息后更新活跃度估算
+func (c *Client) said() {
+	if c.ln != nil {
+		c.ln.Say()
+	}
+}
+
+// Start 初始化活跃度统计
+func (c *Client) Start() {
 	liveness, e := c.sdk.UserLiveness()
 	if e != nil {
 		liveness = 0
-		fmt.Println("获取当前活跃度失败", e)
+		c.logger.Warn("获取当前活跃度失败", "err", e)
 	}
-	fmt.Println("当前活跃度：", liveness)
+	c.display.Printf("当前活跃度：%v", liveness)
 	f := func(l float64) {
 		l1, e1 := c.sdk.UserLiveness()
 		if e1 != nil {
-			fmt.Println("获取当前活跃度失败", e1)
+			c.logger.Warn("获取当前活跃度失败", "err", e1)
 			return
 		}
 		l = l1
 	}
-	c.ln = NewLnClient(liveness, f)
-	for {
-		var buf [1024]byte
-		read := bufio.NewReader(os.Stdin)
-		m, err := read.Read(buf[:])
-		if err != nil {
-			log.Printf("read error: %s\n", err)
-			continue
-		}
-		recv := strings.Split(string(buf[:m]), "\n")[0]
-		go c.handleSendMsg(recv)
-	}
+	c.ln = NewLnClient(liveness, f, c.display)
 }
 
-const (
-	prefixInfo           = "info-"
-	prefixBreezeMoon     = "bb-"
-	prefixChangeTopic    = "topic-"
-	prefixBreezeMoonList = "bb-list-"
-	prefixBreezeMoonUser = "bb-user-"
-	prefixBarrage        = "barrage-"
-)
-
-func (c *Client) handleSendMsg(msg string) {
-	msg = strings.TrimSpace(msg)
-	if msg == "" {
-		return
-	}
-	if msg == "help" {
-		c.handleHelp()
-		return
-	}
-	if msg == "liveness" {
-		c.handleLiveness()
-		return
-	}
-	if msg == "reward" {
-		c.handleReward()
-		return
-	}
-	if msg == "stick" {
-		c.eh.Pub(eventHandler.ElvesStick, nil)
-		return
-	}
-	if strings.HasPrefix(msg, prefixInfo) {
-		name := strings.TrimPrefix(msg, prefixInfo)
-		c.logger.Log(c.sdk.UserInfo(name))
+// HandleInput 处理终端输入 以/开头的输入和旧的指令写法作为指令 其余输入作为消息发送 //开头的输入去掉一个/后发送
+func (c *Client) HandleInput(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
 		return
 	}
 
-	if strings.HasPrefix(msg, prefixBreezeMoonList) {
-		msg = strings.TrimPrefix(msg, prefixBreezeMoonList)
-		if err := c.sdk.BreezeMoonList(msg); err != nil {
-			fmt.Println(err)
-			return
-		}
-	} else if strings.HasPrefix(msg, prefixBreezeMoonUser) {
-		msg = strings.TrimPrefix(msg, prefixBreezeMoonUser)
-		if err := c.sdk.BreezeMoonUser(msg); err != nil {
-			fmt.Println(err)
-			return
-		}
-	} else if strings.HasPrefix(msg, prefixBreezeMoon) {
-		msg = strings.TrimPrefix(msg, prefixBreezeMoon)
-		if err := c.sdk.SendBreezeMoon(msg); err != nil {
-			fmt.Println(err)
-			return
-		}
+	var err error
+	if cmd, ok := strings.CutPrefix(line, replPrefix); ok && !strings.HasPrefix(cmd, replPrefix) {
+		err = c.commands.Exec(cmd, c.display)
+	} else if isLegacyCommand(line) {
+		err = c.commands.Exec(line, c.display)
 	} else {
-		if strings.HasPrefix(msg, prefixChangeTopic) {
-			msg = fmt.Sprintf("[setdiscuss]%s[/setdiscuss]", strings.TrimPrefix(msg, prefixChangeTopic))
-		}
-		if strings.HasPrefix(msg, prefixBarrage) {
-			msg = strings.TrimPrefix(msg, prefixBarrage)
-			color := "#66CCFF"
-			msg = fmt.Sprintf(`[barrager]{"color":"%s","content":"%s"}[/barrager]`, color, msg)
-		}
-		if err := c.sdk.SendMsg(msg); err != nil {
-			fmt.Println(err)
-			return
-		}
-		c.ln.Say()
+		err = c.env.send(strings.TrimPrefix(line, replPrefix))
 	}
-	fmt.Println()
-	fmt.Println()
-	fmt.Println()
-}
-
-func (c *Client) handleHelp() {
-	help := `help - 查看帮助信息
-liveness - 查询当前活跃度（官方查询时间间隔建议为30s 本程序未作限制）
-reward - 查询昨日活跃奖励是否已经领取并自动领取
-stick - 召唤小飞棍
-info-{username} - 查询用户信息 {username}为想要查询的用户的用户名
-bb-{messsage} - 发布明月清风
-topic-{new topic content} - 发布新话题
-bb-list-{20-1} - 获取明月清风 每页20条 第一页
-bb-user-{username-20-1} 获取username的明月清风 每页20条 第一页
-
-其余信息将作为普通信息直接发送`
-
-	c.logger.Log(help)
-}
-
-func (c *Client) handleLiveness() {
-	ln, err := c.sdk.UserLiveness()
 	if err != nil {
-		c.logger.Logf("获取活跃度失败：%s", err)
-		return
+		c.display.Print(err.Error())
 	}
-	c.logger.Logf("当前活跃度：%.2f", ln)
 }
 
-func (c *Client) handleReward() {
-	b, e := c.sdk.IsCollectedLiveness()
-	if e != nil {
-		c.logger.Logf("查询是否领取昨日活跃奖励失败 %s", e)
-		return
-	}
-	if b {
-		c.logger.Log("已经领取了昨日活跃奖励")
-		return
-	}
-	point, err := c.sdk.DrawYesterdayLivenessReward()
-	if err != nil {
-		c.logger.Logf("领取昨日活跃奖励失败 %s", err)
-		return
-	}
-	c.logger.Logf("领到%s积分", point)
+// Commands 发送端支持的指令 其余输入作为普通消息发送
+func (c *Client) Commands() *command.Registry {
+	return c.commands
 }
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+)
+
+func TestClientInput(t *testing.T) {
+	var sent, moons []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data sendMsgData
+		switch r.URL.Path {
+		case "/chat-room/more":
+			fmt.Fprint(w, `{"code":0,"data":[]}`)
+		case "/chat-room/send":
+			_ = json.NewDecoder(r.Body).Decode(&data)
+			sent = append(sent, data.Content)
+			fmt.Fprint(w, `{"code":0}`)
+		case "/breezemoon":
+			moons = append(moons, "bb")
+			fmt.Fprint(w, `{"code":0}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	api, _ := NewApi(srv.URL)
+	sdk := NewSdk(api, "test", "key", "me", l)
+	eh := eventHandler.NewBus("test", l)
+	display := new(testDisplay)
+
+	// 以指令名开头的普通消息直接发送 旧的写法和/开头的输入作为指令
+	c := NewClient(sdk, eh, display, l)
+	for _, line := range []string{"bb 你好", "topic 今天吃啥", "search 摸鱼", "topic-新话题", "/topic 新话题2", "//help"} {
+		c.HandleInput(line)
+	}
+	want := []string{"bb 你好", "topic 今天吃啥", "search 摸鱼", "[setdiscuss]新话题[/setdiscuss]", "[setdiscuss]新话题2[/setdiscuss]", "/help"}
+	if strings.Join(sent, "|") != strings.Join(want, "|") || len(moons) != 0 {
+		t.Fatalf("sent %q, moons %q", sent, moons)
+	}
+
+	// 接收端的topic-发送消息并附带当前话题 不修改话题
+	sent = nil
+	h := NewHandler(20, sdk, eh, display, l)
+	h.HandleInput("topic-摸鱼")
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], "摸鱼\n") || strings.Contains(sent[0], "setdiscuss") {
+		t.Fatalf("sent %q", sent)
+	}
+}
@@ -0,0 +1,361 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fishpi/archive"
+	"fishpi/block"
+	"fishpi/command"
+	"fishpi/eventHandler"
+	"fishpi/redpacket"
+)
+
+// commandEnv 指令依赖的功能 为nil的功能对应的指令不可用
+type commandEnv struct {
+	sdk     *Sdk
+	eh      *eventHandler.Bus
+	archive func() *archive.Archive
+	blocks  func() *block.List
+	topic   func() string // 当前话题 只有接收端知道
+	said    func()        // 发送消息后调用 用于活跃度统计
+}
+
+// register 注册只依赖接口的指令 所有模式共用
+func (e *commandEnv) register(r *command.Registry) {
+	r.Register(
+		&command.Command{
+			Name:    "info",
+			Aliases: []string{"info-"},
+			Usage:   "{username}",
+			Help:    "查询用户信息",
+			MinArgs: 1,
+			Run: func(c *command.Context) error {
+				c.Out.Print(e.sdk.UserInfo(c.Arg(0)))
+				return nil
+			},
+		},
+		&command.Command{
+			Name: "liveness",
+			Help: "查询当前活跃度（官方查询时间间隔建议为30s 本程序未作限制）",
+			Run: func(c *command.Context) error {
+				ln, err := e.sdk.UserLiveness()
+				if err != nil {
+					return fmt.Errorf("获取活跃度失败：%w", err)
+				}
+				c.Out.Printf("当前活跃度：%.2f", ln)
+				return nil
+			},
+		},
+		&command.Command{
+			Name: "reward",
+			Help: "查询昨日活跃奖励是否已经领取并自动领取",
+			Run:  e.reward,
+		},
+		&command.Command{
+			Name: "stick",
+			Help: "召唤小飞棍",
+			Run: func(c *command.Context) error {
+				eventHandler.Publish(e.eh, eventHandler.TopicElvesStick, struct{}{})
+				return nil
+			},
+		},
+		&command.Command{
+			Name:  "topic",
+			Usage: "[新话题]",
+			Help:  "查看当前话题 或者发布新话题",
+			Run: func(c *command.Context) error {
+				if c.Raw == "" {
+					if e.topic == nil {
+						return errors.New("只有接收端可以查看当前话题")
+					}
+					c.Out.Print(e.topic())
+					return nil
+				}
+				return e.send(fmt.Sprintf("[setdiscuss]%s[/setdiscuss]", c.Raw))
+			},
+		},
+		&command.Command{
+			Name:    "barrage",
+			Aliases: []string{"barrage-"},
+			Usage:   "{内容}",
+			Help:    "发送弹幕",
+			MinArgs: 1,
+			Run: func(c *command.Context) error {
+				return e.send(fmt.Sprintf(`[barrager]{"color":"%s","content":"%s"}[/barrager]`, "#66CCFF", c.Raw))
+			},
+		},
+		&command.Command{
+			Name:    "bb",
+			Aliases: []string{"bb-"},
+			Usage:   "{内容}",
+			Help:    "发布明月清风",
+			MinArgs: 1,
+			Run: func(c *command.Context) error {
+				return e.sdk.SendBreezeMoon(c.Raw)
+			},
+		},
+		&command.Command{
+			Name:    "bb-list",
+			Aliases: []string{"bb-list-"},
+			Usage:   "[size] [page]",
+			Help:    "获取明月清风 例如 bb-list 20 1 每页20条 第一页",
+			Run: func(c *command.Context) error {
+				list, err := e.sdk.BreezeMoonList(strings.Join(c.Args, "-"))
+				if err != nil {
+					return err
+				}
+				c.Out.Print(list)
+				return nil
+			},
+		},
+		&command.Command{
+			Name:    "bb-user",
+			Aliases: []string{"bb-user-"},
+			Usage:   "{username} [size] [page]",
+			Help:    "获取用户的明月清风 例如 bb-user alice 20 1",
+			MinArgs: 1,
+			Run: func(c *command.Context) error {
+				list, err := e.sdk.BreezeMoonUser(strings.Join(c.Args, "-"))
+				if err != nil {
+					return err
+				}
+				c.Out.Print(list)
+				return nil
+			},
+		},
+		&command.Command{
+			Name:     "search",
+			Usage:    "{关键词} [user:用户名] [type:消息类型] [from:2006-01-02] [to:2006-01-02] [limit:20]",
+			Help:     "搜索本地存档的聊天记录",
+			Complete: completeSearch,
+			Run: func(c *command.Context) error {
+				c.Out.Print(Search(e.archive(), e.blocks(), c.Raw))
+				return nil
+			},
+		},
+		&command.Command{
+			Name:  "redpacket-stats",
+			Usage: "[天数] [csv:文件路径]",
+			Help:  "统计打开红包的收益 按日期、类型和发送者分组 可以导出为csv",
+			Run:   e.redPacketStats,
+		},
+		&command.Command{
+			Name:    "block",
+			Aliases: []string{"sb+"},
+			Usage:   "{username} | [user:用户名] [client:客户端] [content:正则] [type:消息类型] [for:2h|7d] [until:2006-01-02]",
+			Help:    "添加屏蔽规则 条件需要全部满足 不设置时长时永久有效",
+			MinArgs: 1,
+			Run: func(c *command.Context) error {
+				l := e.blocks()
+				if l == nil {
+					return errBlockUnavailable
+				}
+				r, err := block.ParseRule(c.Args, time.Now())
+				if err != nil {
+					return err
+				}
+				if _, err = l.Add(r); err != nil {
+					return err
+				}
+				c.Out.Printf("已添加屏蔽规则 %s", r)
+				return nil
+			},
+		},
+		&command.Command{
+			Name:    "unblock",
+			Aliases: []string{"sb-"},
+			Usage:   "{编号|username}",
+			Help:    "删除屏蔽规则 按用户名删除时会删除该用户的所有规则",
+			MinArgs: 1,
+			Run: func(c *command.Context) error {
+				l := e.blocks()
+				if l == nil {
+					return errBlockUnavailable
+				}
+				n, err := l.Remove(c.Arg(0))
+				if err != nil {
+					return err
+				}
+				if n == 0 {
+					return fmt.Errorf("没有找到屏蔽规则：%s", c.Arg(0))
+				}
+				c.Out.Printf("已删除%d条屏蔽规则", n)
+				return nil
+			},
+		},
+		&command.Command{
+			Name: "blocks",
+			Help: "查看当前有效的屏蔽规则",
+			Run: func(c *command.Context) error {
+				l := e.blocks()
+				if l == nil {
+					return errBlockUnavailable
+				}
+				rules := l.Rules()
+				if len(rules) == 0 {
+					c.Out.Print("没有屏蔽规则")
+					return nil
+				}
+				lines := make([]string, 0, len(rules))
+				for _, r := range rules {
+					lines = append(lines, r.String())
+				}
+				c.Out.Print(strings.Join(lines, "\n"))
+				return nil
+			},
+		},
+	)
+}
+
+var errBlockUnavailable = errors.New("屏蔽规则不可用")
+
+// 发送端旧的指令写法 其余输入都作为消息发送 新的指令需要以/开头
+var (
+	legacyWords    = []string{"help", "liveness", "reward", "stick"}
+	legacyPrefixes = []string{"info-", "bb-", "topic-", "barrage-"}
+)
+
+// isLegacyCommand 是否为发送端旧的指令写法
+func isLegacyCommand(line string) bool {
+	if slices.Contains(legacyWords, line) {
+		return true
+	}
+	for _, prefix := range legacyPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *commandEnv) send(msg string) error {
+	if err := e.sdk.SendMsg(msg); err != nil {
+		return err
+	}
+	if e.said != nil {
+		e.said()
+	}
+	return nil
+}
+
+func (e *commandEnv) reward(c *command.Context) error {
+	b, err := e.sdk.IsCollectedLiveness()
+	if err != nil {
+		return fmt.Errorf("查询是否领取昨日活跃奖励失败 %w", err)
+	}
+	if b {
+		c.Out.Print("已经领取了昨日活跃奖励")
+		return nil
+	}
+	point, err := e.sdk.DrawYesterdayLivenessReward()
+	if err != nil {
+		return fmt.Errorf("领取昨日活跃奖励失败 %w", err)
+	}
+	c.Out.Printf("领到%s积分", point)
+	return nil
+}
+
+func (e *commandEnv) redPacketStats(c *command.Context) error {
+	ledger := e.sdk.Ledger()
+	if ledger == nil {
+		return errors.New("红包账本不可用")
+	}
+	var from time.Time
+	var csvPath string
+	for _, arg := range c.Args {
+		if path, ok := strings.CutPrefix(arg, "csv:"); ok {
+			csvPath = path
+			continue
+		}
+		days, err := strconv.Atoi(arg)
+		if err != nil || days <= 0 {
+			return fmt.Errorf("天数应当是正整数：%s", arg)
+		}
+		y, m, d := time.Now().AddDate(0, 0, 1-days).Date()
+		from = time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	}
+
+	entries, err := ledger.Entries(from)
+	if err != nil {
+		return fmt.Errorf("读取红包账本失败 %w", err)
+	}
+	report := redpacket.NewReport(entries)
+	if csvPath == "" {
+		c.Out.Print(report.String())
+		return nil
+	}
+	f, err := os.Create(csvPath)
+	if err != nil {
+		return err
+	}
+	if err = report.WriteCSV(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	c.Out.Printf("已导出%d个红包的统计到 %s", len(entries), csvPath)
+	return nil
+}
+
+// completeSearch 补全搜索条件的名称和消息类型
+func completeSearch(args []string) []string {
+	last := args[len(args)-1]
+	if value, ok := strings.CutPrefix(last, "type:"); ok {
+		var result []string
+		for _, t := range []string{archive.TypeMsg, archive.TypeRevoke, archive.TypeRedPacketStatus, archive.TypeBarrage, archive.TypeDiscussChanged} {
+			if strings.HasPrefix(t, value) {
+				result = append(result, "type:"+t)
+			}
+		}
+		return result
+	}
+	if last == "" {
+		return nil
+	}
+	var result []string
+	for _, name := range []string{"user:", "type:", "from:", "to:", "limit:"} {
+		if strings.HasPrefix(name, last) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// handlerCommands 接收端的指令 依赖收到的消息 said为发送消息后的回调 可以为nil
+func (h *Handler) handlerCommands(r *command.Registry, said func()) {
+	sentCommands(r, h.sdk, &h.sent)
+	r.Register(replyCommand(h.sdk, func(oId string) *WsMsgReply { return findMessage(&h.cache, h.archive, oId) }, said))
+	packetCommands(r, h.sdk, &h.packets)
+	r.Register(
+		&command.Command{
+			Name: "repeat",
+			Help: "复读最近的一条消息",
+			Run: func(c *command.Context) error {
+				msg := h.cache.latest()
+				if msg == nil {
+					return nil
+				}
+				return h.sdk.SendMsg(msg.Md)
+			},
+		},
+		&command.Command{
+			Name:    "say-topic",
+			Usage:   "{消息}",
+			Help:    "发送消息并附带当前话题",
+			MinArgs: 1,
+			Run:     h.sayTopic,
+		},
+	)
+}
+
+func (h *Handler) sayTopic(c *command.Context) error {
+	return h.sdk.SendMsg(fmt.Sprintf("%s\n*`# %s #`*", c.Raw, h.topic()))
+}
@@ -2,58 +2,52 @@ package core
 
 import (
 	"encoding/json"
+	"fishpi/archive"
+	"fishpi/block"
+	"fishpi/command"
 	"fishpi/eventHandler"
+	"fishpi/logger"
+	"fishpi/transform"
+	"sync/atomic"
 	"time"
 )
 
 type Core struct {
-	oldTopic  *WsMsgReply   // 旧标题
-	red       *WsMsgReply   // 拼手气红包、平分红包
-	gesture   *WsMsgReply   // 猜拳红包
-	heartbeat *WsMsgReply   // 心跳红包
-	own       *WsMsgReply   // 专属红包
-	lastest   *WsMsgReply   // 最近一条消息
-	cache     []*WsMsgReply // 消息缓存
+	packets pendingPackets // 未领完的红包
+	sent    sentHistory    // 自己最近发送的消息
+	cache   msgCache       // 消息缓存
 
 	msgChannel   chan *WsMsgReply
 	showMsgCache []*WsMsgReply
+	filter       *Filter             // 配置文件中的过滤规则
+	archive      *archive.Archive    // 聊天记录存档 未开启时为nil
+	blocks       *block.List         // 屏蔽规则 未开启时为nil
+	commands     *command.Registry   // 终端指令
+	pipeline     *transform.Pipeline // 消息内容改写插件 未设置时不改写
 
-	cacheNum int
-	token    string
+	cacheNum atomic.Int64
 	sdk      *Sdk
-	eh       eventHandler.EventHandler
+	eh       *eventHandler.Bus
 }
 
-func NewCore(cacheNum int, token string, sdk *Sdk, eh eventHandler.EventHandler) *Core {
+func NewCore(cacheNum int, sdk *Sdk, eh *eventHandler.Bus) *Core {
 	c := &Core{
-		cacheNum: cacheNum,
-		token:    token,
-		sdk:      sdk,
-		eh:       eh,
+		sdk: sdk,
+		eh:  eh,
 	}
+	c.cacheNum.Store(int64(cacheNum))
+
+	c.commands = command.NewRegistry()
+	env := &commandEnv{sdk: sdk, eh: eh, archive: func() *archive.Archive { return c.archive }, blocks: func() *block.List { return c.blocks }}
+	env.register(c.commands)
+	sentCommands(c.commands, sdk, &c.sent)
+	c.commands.Register(replyCommand(sdk, func(oId string) *WsMsgReply { return findMessage(&c.cache, c.archive, oId) }, nil))
+	packetCommands(c.commands, sdk, &c.packets)
 
-	c.init()
 	c.KeepLive()
 	return c
 }
 
-func (c *Core) init() {
-	//data, err := c.sdk.ChatRecordPage(1)
-	//if err != nil {
-	//	//c.showMsg(fmt.Sprintf("获取历史聊天记录失败 %s", err.Error()))
-	//	return
-	//}
-	//sort.Slice(data, func(i, j int) bool {
-	//	t1, _ := time.Parse("2006-01-02 15:04:05", data[i].Time)
-	//	t2, _ := time.Parse("2006-01-02 15:04:05", data[j].Time)
-	//	return t1.Before(t2)
-	//})
-	//for _, v := range data {
-	//	//content := strings.TrimPrefix(strings.TrimSuffix(v.Content, "</p>"), "<p>")
-	//	//c.showMsg(fmt.Sprintf("%s %s(%s): %s", v.Time[11:], v.UserNickname, v.UserName, content))
-	//}
-}
-
 // SendPublicMsg 发送消息
 func (c *Core) SendPublicMsg(content string) error {
 	return c.sdk.SendMsg(content)
@@ -64,103 +58,145 @@ func (c *Core) GetUserInfo(username string) string {
 	return c.sdk.UserInfo(username)
 }
 
-// OpenRedPacket 打开红包
-func (c *Core) OpenRedPacket(oId, gesture string) (string, error) {
-	return c.sdk.OpenRedPacket(oId, gesture)
+// IsOwn 是否为自己最近发送的消息 可以撤回
+func (c *Core) IsOwn(msg *WsMsgReply) bool {
+	return msg.UserName == c.sdk.username && c.sent.contains(msg.OId)
 }
 
-func (c *Core) HandleMsg(data interface{}) {
-	bytes, ok := data.([]byte)
-	if !ok {
-		return
+// Revoke 撤回自己发送的消息
+func (c *Core) Revoke(msg *WsMsgReply) error {
+	return c.sent.revoke(c.sdk, msg)
+}
+
+// OpenRedPacket 打开红包 打开后从未领完的红包中删除
+func (c *Core) OpenRedPacket(msg *WsMsgReply, gesture string) (string, error) {
+	result, err := c.sdk.OpenRedPacketResult(msg.OId, msg.JsonInfo.Type, gesture)
+	if err != nil {
+		return "", err
 	}
+	c.packets.remove(msg.OId)
+	return result.String(), nil
+}
+
+// PacketType 未领完的红包的类型 找不到时返回空字符串 用于bridge等只知道oId的调用方
+func (c *Core) PacketType(oId string) string {
+	return c.packets.typeOf(oId)
+}
 
+func (c *Core) HandleMsg(bytes []byte) {
 	msg := &WsMsgReply{}
 	if err := json.Unmarshal(bytes, &msg); err != nil {
 		return
 	}
 	msg.Parse()
+	transformMsg(c.pipeline, msg)
 	c.filterMessage(msg)
 
-	//content := msg.Msg()
-	//if msg.Type == WsMsgTypeOnline {
-	//	if c.oldTopic == nil {
-	//		c.oldTopic = msg
-	//		return
-	//	}
-	//	if msg.Msg() == c.oldTopic.Msg() {
-	//		return
-	//	}
-	//	c.oldTopic = msg
-	//} else if msg.Type == WsMsgTypeRevoke {
-	//	content = fmt.Sprintf("有人撤回了一条消息 消息内容不知道 %s %s", msg.OId, msg.UserAvatarURL210)
-	//	for _, v := range c.cache {
-	//		if msg.OId == v.OId {
-	//			content = fmt.Sprintf("有人撤回了一条消息：%s", v.Msg())
-	//			break
-	//		}
-	//	}
-	//} else if strings.Contains(content, `<span class="kaibai">`) {
-	//	pattern := `<span class="kaibai">[a-z,A-z,0-9]+<\/span>`
-	//	re := regexp.MustCompile(pattern)
-	//	code := re.FindString(content)
-	//	code = strings.TrimSuffix(strings.TrimPrefix(code, `<span class="kaibai">`), `</span>`)
-	//	code = fmt.Sprintf("https://sexy.1433.top/%s?token=%s", code, c.token)
-	//	content = re.ReplaceAllString(content, code)
-	//}
-
+	if c.filter.Block(msg) || c.blocks.Block(blockMessage(msg)) {
+		return
+	}
 	c.showMsg(msg)
 }
 
-func (c *Core) addCache(msg *WsMsgReply) {
-	c.cache = append(c.cache, msg)
-	if len(c.cache) >= c.cacheNum {
-		removeNum := len(c.cache) - c.cacheNum
-		c.cache = c.cache[removeNum:]
+// SetPipeline 设置消息内容改写插件
+func (c *Core) SetPipeline(p *transform.Pipeline) *Core {
+	c.pipeline = p
+	return c
+}
+
+// SetFilter 设置配置文件中的过滤规则
+func (c *Core) SetFilter(f *Filter) *Core {
+	c.filter = f
+	return c
+}
+
+// SetArchive 设置聊天记录存档 用于搜索
+func (c *Core) SetArchive(arc *archive.Archive) *Core {
+	c.archive = arc
+	return c
+}
+
+// Search 搜索本地存档的聊天记录 参数见 archive.ParseQuery
+func (c *Core) Search(args string) string {
+	return Search(c.archive, c.blocks, args)
+}
+
+// SetBlocks 设置屏蔽规则
+func (c *Core) SetBlocks(l *block.List) *Core {
+	c.blocks = l
+	return c
+}
+
+// BlockUser 永久屏蔽用户的所有消息
+func (c *Core) BlockUser(username string) (*block.Rule, error) {
+	if c.blocks == nil {
+		return nil, errBlockUnavailable
+	}
+	r := &block.Rule{User: username}
+	if _, err := c.blocks.Add(r); err != nil {
+		return nil, err
 	}
+	return r, nil
+}
+
+// Exec 执行指令 结果输出到out
+func (c *Core) Exec(line string, out logger.Display) error {
+	return c.commands.Exec(line, out)
+}
+
+// Complete 补全指令
+func (c *Core) Complete(line string) []string {
+	return c.commands.Complete(line)
+}
+
+// SetCacheNum 修改消息缓存数量 下一条消息时生效
+func (c *Core) SetCacheNum(cacheNum int) {
+	c.cacheNum.Store(int64(cacheNum))
+}
+
+func (c *Core) addCache(msg *WsMsgReply) {
+	c.cache.add(msg, int(c.cacheNum.Load()))
 }
 
 func (c *Core) filterMessage(msg *WsMsgReply) {
-	//if msg.IsRedPacketMsg() {
-	//	switch msg.RedPackageInfo.Type {
-	//	case RedPacketTypeSpecify:
-	//		h.own = msg
-	//	case RedPacketTypeRockPaperScissors:
-	//		h.gesture = msg
-	//	case RedPacketTypeHeartbeat:
-	//		h.heartbeat = msg
-	//	default:
-	//		h.red = msg
-	//	}
-	//	return
-	//}
+	if msg.IsRedPacketMsg() {
+		eventHandler.Publish(c.eh, TopicRedPacket, msg)
+		c.packets.add(msg)
+	}
+
+	if msg.Type == WsMsgTypeRedPacketStatus {
+		eventHandler.Publish(c.eh, TopicRedPacketStatus, msg)
+		c.packets.update(msg)
+	}
 
 	if msg.Type == WsMsgTypeMsg {
 		c.addCache(msg)
 
 		if msg.UserName == c.sdk.username {
-			c.lastest = msg
+			c.sent.add(msg)
 		}
 	}
-}
 
-func (c *Core) HandleWsStatusMsg(data interface{}) {
-	msg, ok := data.(*WsMsgReply)
-	if !ok {
-		return
+	if msg.Type == WsMsgTypeRevoke {
+		c.sent.remove(msg.OId)
 	}
-	c.showMsg(msg)
 }
 
-func (c *Core) KeepLive() {
-	ticker := time.NewTicker(3 * time.Minute)
-	defer ticker.Stop()
+func (c *Core) HandleWsStatusMsg(state eventHandler.ConnState) {
+	c.showMsg(&WsMsgReply{
+		Type:    WsMsgTypeCustomMessage,
+		Message: state.String(),
+	})
+}
 
+func (c *Core) KeepLive() {
 	go func() {
+		ticker := time.NewTicker(3 * time.Minute)
+		defer ticker.Stop()
 		for {
 			select {
 			case _ = <-ticker.C:
-				c.eh.Pub(eventHandler.WsSend, "-hb-")
+				eventHandler.Publish(c.eh, eventHandler.TopicWsSend, []byte("-hb-"))
 			}
 		}
 	}()
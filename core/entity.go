@@ -4,17 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
 	"log/slog"
 	"math/rand"
 	"net/url"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/olekukonko/tablewriter"
 	"github.com/olekukonko/tablewriter/tw"
 )
@@ -267,6 +264,8 @@ type WsMsgReply struct {
 	Md               string        `json:"md"`               // 消息内容 Markdown格式，红包消息无此栏位
 	SysMetalInfo     *SysMetalInfo // 徽章数据解析
 	JsonInfo         *JsonInfo     // json内容解析
+	Quote            *Quote        // 引用的消息 没有引用时为nil
+	Display          *string       `json:"-"` // 插件改写后用于展示的正文 为nil时展示Md 原始的Md用于复读和引用
 
 	// 红包领取消息
 	Count   int    `json:"count"`   // 红包个数
@@ -286,6 +285,9 @@ type WsMsgReply struct {
 }
 
 func (w *WsMsgReply) Parse() {
+	if w.Type == WsMsgTypeMsg && w.Md != "" {
+		w.Quote, _ = ParseQuote(w.Md)
+	}
 	if w.Content == "" {
 		return
 	}
@@ -300,12 +302,38 @@ func (w *WsMsgReply) Parse() {
 
 	rp := new(JsonInfo)
 	if err := json.Unmarshal([]byte(w.Content), rp); err != nil {
-		slog.Info("解析JSON数据失败", slog.Any("err", err), slog.String("content", w.Content))
+		slog.Warn("解析JSON数据失败", slog.String("component", "core"), slog.Any("err", err), slog.String("content", w.Content))
 		return
 	}
 	w.JsonInfo = rp
 }
 
+// Body 用于展示的聊天消息正文 去掉了引用、引用块和空行
+func (w *WsMsgReply) Body() string {
+	body := w.rawBody()
+	if w.Display != nil {
+		body = *w.Display
+	}
+
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, ">") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rawBody 去掉引用后的原始正文
+func (w *WsMsgReply) rawBody() string {
+	if w.Md != "" {
+		_, body := ParseQuote(w.Md)
+		return body
+	}
+	return w.Content
+}
+
 func (w *WsMsgReply) IsRedPacketMsg() bool {
 	rp := w.JsonInfo
 	return rp != nil && rp.MsgType == JsonMsgTypeRedPacket
@@ -335,40 +363,11 @@ func (w *WsMsgReply) Msg() string {
 			} else {
 				result = fmt.Sprintf("%s %s(%s): 发送了未处理的JSON数据(%s)%s", w.Time[11:], w.UserNickname, w.UserName, rp.MsgType, w.Content)
 			}
-		} else if strings.Contains(w.Content, "https://www.lingmx.com/card/index2.html") {
-			result = w.decodeWeatherMsg()
-		} else if strings.Contains(w.Content, "https://www.lingmx.com/card/index.html") {
-			result = w.decodeSingleWeatherMsg()
 		} else {
-			content := func() string {
-				if w.Md != "" {
-					return w.Md
-				}
-				return w.Content
-			}()
-
-			content = func(msg string) string {
-				strs := strings.Split(msg, "\n")
-
-				var ss []string
-				for _, str := range strs {
-					if strings.HasPrefix(str, ">") ||
-						strings.HasPrefix(str, "##### 引用") ||
-						strings.TrimSpace(str) == "" ||
-						strings.Contains(str, "https://zsh4869.github.io/fishpi.io/?hyd=") ||
-						strings.Contains(str, "extension-message") ||
-						strings.Contains(str, ":sweat_drops:") ||
-						strings.Contains(str, "下次更新时间") ||
-						strings.Contains(str, "https://unv-shield.librian.net/api/unv_shield") ||
-						strings.Contains(str, "EXP") {
-						continue
-					}
-					ss = append(ss, str)
-				}
-
-				return strings.Join(ss, "\n")
-			}(content)
-
+			content := w.Body()
+			if w.Quote != nil {
+				content = fmt.Sprintf("%s [%s]", content, w.Quote.Summary())
+			}
 			result = fmt.Sprintf("%s %s(%s): %s(%s)", w.Time[11:], w.UserNickname, w.UserName, content, w.Client)
 
 		}
@@ -380,95 +379,6 @@ func (w *WsMsgReply) Msg() string {
 	return result
 }
 
-func (w *WsMsgReply) decodeSingleWeatherMsg() string {
-	source := func() string {
-		if w.Md != "" {
-			return w.Md
-		}
-		return w.Content
-	}()
-	pattern1 := `<img src="https:\/\/img\.shields\.io\/badge\/.+">`
-	re1 := regexp.MustCompile(pattern1)
-	codeSource := re1.FindString(source)
-	code := strings.TrimSuffix(strings.TrimPrefix(codeSource, `<img src="https://img.shields.io/badge/`), `">`)
-
-	pattern2 := `<iframe.+iframe>`
-	re2 := regexp.MustCompile(pattern2)
-	linkSource := re2.FindString(source)
-
-	var link string
-	dom, err := goquery.NewDocumentFromReader(strings.NewReader(linkSource))
-	if err != nil {
-		return source
-	}
-	dom.Find("iframe").Each(func(i int, s *goquery.Selection) {
-		link, _ = s.Attr("src")
-	})
-
-	u, e := url.Parse(link)
-	if e != nil {
-		return source
-	}
-	month := u.Query().Get("m")
-	day := u.Query().Get("d")
-	wea := u.Query().Get("w")
-	a := u.Query().Get("a")
-	weather := fmt.Sprintf("%s月%s日, 天气: %s, 当前温度: %s ℃", month, day, wea, a)
-	return strings.ReplaceAll(strings.ReplaceAll(source, codeSource, code), linkSource, weather)
-}
-
-func (w *WsMsgReply) decodeWeatherMsg() string {
-	//str := `<iframe src="https://www.lingmx.com/card/index2.html?date=8/19,8/20,8/21,8/22,8/23&weatherCode=LIGHT_RAIN,LIGHT_RAIN,CLOUDY,CLOUDY,LIGHT_RAIN&max=32,33,35,36,36&min=26,26,26,27,27&t=厦门&st=31分钟后开始下小雨，但56分钟后会停" width="380" height="370" frameborder="0"></iframe>`
-	msg := w.Content
-	dom, err := goquery.NewDocumentFromReader(strings.NewReader(msg))
-	if err != nil {
-		return fmt.Sprintf("parse %s error: %s", w.Content, err)
-	}
-	dom.Find(`iframe`).Each(func(i int, s *goquery.Selection) {
-		src, exist := s.Attr("src")
-		if !exist {
-			return
-		}
-		u, e := url.Parse(src)
-		if e != nil {
-			msg = fmt.Sprintf("parse %s error: %s", src, err)
-			return
-		}
-		msg = u.Query().Get("t") + "天气" + "\n"
-		data := [][]string{
-			strings.Split(u.Query().Get("weatherCode"), ","),
-			strings.Split(u.Query().Get("max"), ","),
-			strings.Split(u.Query().Get("min"), ","),
-		}
-
-		buffer := bytes.NewBufferString(msg)
-		table := tablewriter.NewTable(buffer,
-			tablewriter.WithConfig(tablewriter.Config{
-				Header: tw.CellConfig{
-					Alignment: tw.CellAlignment{
-						Global: tw.AlignCenter,
-					},
-				},
-				Row: tw.CellConfig{
-					Alignment: tw.CellAlignment{
-						Global: tw.AlignCenter,
-					},
-				},
-			}),
-		)
-		table.Header(strings.Split(u.Query().Get("date"), ","))
-		//table.SetColumnAlignment([]int{tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER})
-
-		for _, v := range data {
-			table.Append(v)
-		}
-		table.Render()
-		msg = string(buffer.Bytes())
-		msg += u.Query().Get("st")
-	})
-	return msg
-}
-
 func (w *WsMsgReply) decodeJsonWeatherMsg() string {
 	//msg := `{"date":"4/16,4/17,4/18","st":"未来24小时多云","min":"15.49,17.49,20.49","msgType":"weather","t":"厦门","max":"25.41,26.49,26.49","weatherCode":"PARTLY_CLOUDY_DAY,CLOUDY,CLOUDY","type":"weather"}`
 	msg := ``
@@ -661,7 +571,7 @@ type UserInfoReply struct {
 func (u *UserInfoReply) Parse() {
 	var um UserMetal
 	if err := json.Unmarshal([]byte(u.SysMetal), &um); err != nil {
-		log.Printf("%s的勋章信息解析失败 %s", u.UserName, err)
+		slog.Warn("勋章信息解析失败", slog.String("component", "core"), slog.String("username", u.UserName), slog.Any("err", err))
 		return
 	}
 	u.UserMetal = &um
@@ -1156,3 +1066,64 @@ type ChatroomNodeGetReply struct {
 		Online int    `json:"online"`
 	} `json:"avaliable"`
 }
+
+// ChatChannelMsg 私聊消息
+type ChatChannelMsg struct {
+	OId              string `json:"oId"`              // 消息ID
+	ToId             string `json:"toId"`             // 接收者ID
+	FromId           string `json:"fromId"`           // 发送者ID
+	Time             string `json:"time"`             // 发送时间
+	UserSession      string `json:"user_session"`     // 会话ID
+	SenderUserName   string `json:"senderUserName"`   // 发送者用户名
+	SenderAvatar     string `json:"senderAvatar"`     // 发送者头像
+	ReceiverUserName string `json:"receiverUserName"` // 接收者用户名
+	ReceiverAvatar   string `json:"receiverAvatar"`   // 接收者头像
+	Preview          string `json:"preview"`          // 预览内容
+	Content          string `json:"content"`          // 消息内容 HTML格式
+	Markdown         string `json:"markdown"`         // 消息内容 Markdown格式
+}
+
+func (c *ChatChannelMsg) Msg() string {
+	content := c.Markdown
+	if content == "" {
+		content = c.Preview
+	}
+	t := c.Time
+	if len(t) > 11 {
+		t = t[11:]
+	}
+	return fmt.Sprintf("%s %s -> %s: %s", t, c.SenderUserName, c.ReceiverUserName, content)
+}
+
+const (
+	UserChannelCommandRefreshNotification = "refreshNotification"    // 通知刷新
+	UserChannelCommandChatUnreadCount     = "chatUnreadCountRefresh" // 私聊未读数刷新
+	UserChannelCommandNewIdleChatMessage  = "newIdleChatMessage"     // 新的私聊消息
+	UserChannelCommandWarnBroadcast       = "warnBroadcast"          // 全局公告
+)
+
+// UserChannelMsg 用户通知消息
+type UserChannelMsg struct {
+	Command           string `json:"command"`           // 通知类型
+	UserId            string `json:"userId"`            // 用户ID
+	Count             int    `json:"count"`             // 未读数
+	SenderUserName    string `json:"senderUserName"`    // 私聊发送者
+	Preview           string `json:"preview"`           // 私聊预览
+	WarnBroadcastText string `json:"warnBroadcastText"` // 公告内容
+	Who               string `json:"who"`               // 公告发布者
+}
+
+func (u *UserChannelMsg) Msg() string {
+	switch u.Command {
+	case UserChannelCommandRefreshNotification:
+		return "收到新的通知"
+	case UserChannelCommandChatUnreadCount:
+		return fmt.Sprintf("私聊未读消息：%d", u.Count)
+	case UserChannelCommandNewIdleChatMessage:
+		return fmt.Sprintf("%s给你发了私聊：%s", u.SenderUserName, u.Preview)
+	case UserChannelCommandWarnBroadcast:
+		return fmt.Sprintf("%s发布了公告：%s", u.Who, u.WarnBroadcastText)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"strings"
+	"sync"
+
+	"fishpi/block"
+)
+
+// Filter 消息过滤规则 来自配置文件 热加载时通过Update更新
+type Filter struct {
+	mu         sync.RWMutex
+	blockUsers map[string]struct{}
+	keywords   []string
+}
+
+func NewFilter(blockUsers, keywords []string) *Filter {
+	f := new(Filter)
+	f.Update(blockUsers, keywords)
+	return f
+}
+
+func (f *Filter) Update(blockUsers, keywords []string) {
+	users := make(map[string]struct{}, len(blockUsers))
+	for _, u := range blockUsers {
+		users[u] = struct{}{}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blockUsers = users
+	f.keywords = keywords
+}
+
+// Block 消息是否需要屏蔽 只过滤用户发送的消息
+func (f *Filter) Block(msg *WsMsgReply) bool {
+	if f == nil || msg.UserName == "" {
+		return false
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if _, ok := f.blockUsers[msg.UserName]; ok {
+		return true
+	}
+	if msg.Type != WsMsgTypeMsg || msg.IsRedPacketMsg() {
+		return false
+	}
+	for _, k := range f.keywords {
+		if strings.Contains(msg.Md, k) || strings.Contains(msg.Content, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockMessage 转换为屏蔽规则使用的消息
+func blockMessage(msg *WsMsgReply) *block.Message {
+	m := &block.Message{Type: msg.Type, UserName: msg.UserName, Client: msg.Client, Content: msg.Md}
+	if m.Content == "" {
+		m.Content = msg.Content
+	}
+	switch msg.Type {
+	case WsMsgTypeBarrage:
+		m.Content = msg.BarrageContent
+	case WsMsgTypeRedPacketStatus:
+		m.UserName = msg.WhoGot
+	}
+	return m
+}
@@ -1,43 +1,56 @@
 package core
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
-	"log"
-	"os"
-	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"fishpi/archive"
+	"fishpi/block"
+	"fishpi/command"
+	"fishpi/eventHandler"
 	"fishpi/logger"
+	"fishpi/transform"
 )
 
 type Handler struct {
-	oldTopic  *WsMsgReply         // 旧标题
-	red       *WsMsgReply         // 拼手气红包、平分红包
-	gesture   *WsMsgReply         // 猜拳红包
-	heartbeat *WsMsgReply         // 心跳红包
-	own       *WsMsgReply         // 专属红包
-	lastest   *WsMsgReply         // 最近一条消息
-	cache     []*WsMsgReply       // 消息缓存
-	sbMap     map[string]struct{} // 屏蔽名单
-
-	cacheNum int
-	token    string
+	mu       sync.Mutex          // 保护oldTopic topic指令在输入的goroutine中读取
+	oldTopic *WsMsgReply         // 旧标题
+	packets  pendingPackets      // 未领完的红包
+	sent     sentHistory         // 自己最近发送的消息
+	cache    msgCache            // 消息缓存
+	filter   *Filter             // 配置文件中的过滤规则
+	archive  *archive.Archive    // 聊天记录存档 未开启时为nil
+	blocks   *block.List         // 屏蔽规则 未开启时为nil
+	commands *command.Registry   // 终端指令
+	pipeline *transform.Pipeline // 消息内容改写插件 未设置时不改写
+
+	cacheNum atomic.Int64
 	sdk      *Sdk
+	eh       *eventHandler.Bus
+	display  logger.Display
 	logger   logger.Logger
 }
 
-func NewHandler(cacheNum int, token string, sdk *Sdk, logger logger.Logger) *Handler {
+func NewHandler(cacheNum int, sdk *Sdk, eh *eventHandler.Bus, display logger.Display, logger logger.Logger) *Handler {
 	h := &Handler{
-		cacheNum: cacheNum,
-		token:    token,
-		sbMap:    make(map[string]struct{}),
-		sdk:      sdk,
-		logger:   logger,
+		sdk:     sdk,
+		eh:      eh,
+		display: display,
+		logger:  logger.Named("core"),
 	}
+	h.cacheNum.Store(int64(cacheNum))
+
+	h.commands = command.NewRegistry()
+	env := &commandEnv{sdk: sdk, eh: eh, archive: func() *archive.Archive { return h.archive }, blocks: func() *block.List { return h.blocks }, topic: h.topic}
+	env.register(h.commands)
+	h.handlerCommands(h.commands, nil)
+	// 接收端旧的写法topic-{消息}发送消息并附带当前话题
+	h.commands.Register(&command.Command{Name: "topic-", Usage: "{消息}", Help: "同say-topic", MinArgs: 1, Run: h.sayTopic})
 
 	h.init()
 	return h
@@ -46,7 +59,7 @@ func NewHandler(cacheNum int, token string, sdk *Sdk, logger logger.Logger) *Han
 func (h *Handler) init() {
 	data, err := h.sdk.ChatRecordPage(1)
 	if err != nil {
-		h.logger.Logf("获取历史聊天记录失败 %s", err.Error())
+		h.logger.Warn("获取历史聊天记录失败", "err", err)
 		return
 	}
 	sort.Slice(data, func(i, j int) bool {
@@ -56,78 +69,86 @@ func (h *Handler) init() {
 	})
 	for _, v := range data {
 		content := strings.TrimPrefix(strings.TrimSuffix(v.Content, "</p>"), "<p>")
-		h.logger.Logf("%s %s(%s): %s", v.Time[11:], v.UserNickname, v.UserName, content)
+		h.display.Printf("%s %s(%s): %s", v.Time[11:], v.UserNickname, v.UserName, content)
 	}
 }
 
+// SetFilter 设置配置文件中的过滤规则
+func (h *Handler) SetFilter(f *Filter) *Handler {
+	h.filter = f
+	return h
+}
+
+// SetArchive 设置聊天记录存档 用于search指令
+func (h *Handler) SetArchive(arc *archive.Archive) *Handler {
+	h.archive = arc
+	return h
+}
+
+// SetBlocks 设置屏蔽规则 用于过滤消息和block指令
+func (h *Handler) SetBlocks(l *block.List) *Handler {
+	h.blocks = l
+	return h
+}
+
+// SetPipeline 设置消息内容改写插件
+func (h *Handler) SetPipeline(p *transform.Pipeline) *Handler {
+	h.pipeline = p
+	return h
+}
+
+// SetCacheNum 修改消息缓存数量 下一条消息时生效
+func (h *Handler) SetCacheNum(cacheNum int) {
+	h.cacheNum.Store(int64(cacheNum))
+}
+
 func (h *Handler) addCache(msg *WsMsgReply) {
-	h.cache = append(h.cache, msg)
-	if len(h.cache) >= h.cacheNum {
-		removeNum := len(h.cache) - h.cacheNum
-		h.cache = h.cache[removeNum:]
-	}
+	h.cache.add(msg, int(h.cacheNum.Load()))
 }
 
-func (h *Handler) HandleMsg(data interface{}) {
-	bytes, ok := data.([]byte)
-	if !ok {
-		h.logger.Logf("data is not []byte: %v", string(bytes))
-		return
-	}
+// PacketType 未领完的红包的类型 找不到时返回空字符串 用于bridge等只知道oId的调用方
+func (h *Handler) PacketType(oId string) string {
+	return h.packets.typeOf(oId)
+}
 
+func (h *Handler) HandleMsg(bytes []byte) {
 	msg := &WsMsgReply{}
 	if err := json.Unmarshal(bytes, &msg); err != nil {
-		log.Printf("parse message error: %s, body: %s\n", err, string(bytes))
+		h.logger.Warn("parse message failed", "err", err, "body", string(bytes))
 		return
 	}
 	msg.Parse()
+	transformMsg(h.pipeline, msg)
 	h.filterMessage(msg)
 
 	content := msg.Msg()
 	if msg.Type == WsMsgTypeOnline {
-		if h.oldTopic == nil {
-			h.oldTopic = msg
-			return
-		}
-		if msg.Msg() == h.oldTopic.Msg() {
+		if !h.changeTopic(msg) {
 			return
 		}
-		h.oldTopic = msg
 	} else if msg.Type == WsMsgTypeRevoke {
 		content = fmt.Sprintf("有人撤回了一条消息 消息内容不知道 %s %s", msg.OId, msg.UserAvatarURL210)
-		for _, v := range h.cache {
-			if msg.OId == v.OId {
-				content = fmt.Sprintf("有人撤回了一条消息：%s", v.Msg())
-				break
-			}
+		if v := h.cache.find(msg.OId); v != nil {
+			content = fmt.Sprintf("有人撤回了一条消息：%s", v.Msg())
 		}
-	} else if strings.Contains(content, `<span class="kaibai">`) {
-		pattern := `<span class="kaibai">[a-z,A-z,0-9]+<\/span>`
-		re := regexp.MustCompile(pattern)
-		code := re.FindString(content)
-		code = strings.TrimSuffix(strings.TrimPrefix(code, `<span class="kaibai">`), `</span>`)
-		code = fmt.Sprintf("https://sexy.1433.top/%s?token=%s", code, h.token)
-		content = re.ReplaceAllString(content, code)
 	}
 
-	if _, ok = h.sbMap[msg.UserName]; ok {
+	if h.filter.Block(msg) || h.blocks.Block(blockMessage(msg)) {
 		return
 	}
-	h.logger.Log(content)
+	h.display.Print(content)
 }
 
 func (h *Handler) filterMessage(msg *WsMsgReply) {
 	if msg.IsRedPacketMsg() {
-		switch msg.JsonInfo.Type {
-		case RedPacketTypeSpecify:
-			h.own = msg
-		case RedPacketTypeRockPaperScissors:
-			h.gesture = msg
-		case RedPacketTypeHeartbeat:
-			h.heartbeat = msg
-		default:
-			h.red = msg
-		}
+		eventHandler.Publish(h.eh, TopicRedPacket, msg)
+		h.packets.add(msg)
+		return
+	}
+
+	if msg.Type == WsMsgTypeRedPacketStatus {
+		eventHandler.Publish(h.eh, TopicRedPacketStatus, msg)
+		h.packets.update(msg)
 		return
 	}
 
@@ -135,30 +156,29 @@ func (h *Handler) filterMessage(msg *WsMsgReply) {
 		h.addCache(msg)
 
 		if msg.UserName == h.sdk.username {
-			h.lastest = msg
+			h.sent.add(msg)
 		}
 	}
-}
 
-func (h *Handler) HandleWsStatusMsg(data interface{}) {
-	str, ok := data.(string)
-	if !ok {
-		h.logger.Logf("data is not string: %v", str)
-		return
+	if msg.Type == WsMsgTypeRevoke {
+		h.sent.remove(msg.OId)
 	}
-	h.logger.Log(str)
 }
 
-func (h *Handler) KeepLive() <-chan string {
-	c := make(chan string)
-	ticker := time.NewTicker(3 * time.Minute)
-	defer ticker.Stop()
+func (h *Handler) HandleWsStatusMsg(state eventHandler.ConnState) {
+	h.display.Print(state.String())
+}
+
+func (h *Handler) KeepLive() <-chan []byte {
+	c := make(chan []byte)
 
 	go func() {
+		ticker := time.NewTicker(3 * time.Minute)
+		defer ticker.Stop()
 		for {
 			select {
 			case _ = <-ticker.C:
-				c <- "-hb-"
+				c <- []byte("-hb-")
 			}
 		}
 	}()
@@ -166,95 +186,42 @@ func (h *Handler) KeepLive() <-chan string {
 	return c
 }
 
-func (h *Handler) Watch() {
-	for {
-		var buf [1024]byte
-		read := bufio.NewReader(os.Stdin)
-		m, err := read.Read(buf[:])
-		if err != nil {
-			log.Printf("read error: %s\n", err)
-			continue
-		}
-		recv := strings.Split(string(buf[:m]), "\n")[0]
-		recv = strings.TrimSpace(recv)
-		if recv == "" {
-			continue
-		}
-		go h.handleCommand(recv)
-	}
-}
-
-func (h *Handler) handleCommand(cmd string) {
-	if cmd == "0" || cmd == "1" || cmd == "2" || cmd == "3" || cmd == "4" || cmd == "5" { // 抢红包 0-普通红包(拼手气 平分) 1-3猜拳红包 4-心跳红包 5-专属红包
-		h.handleReceiveRedPacket(cmd)
-	} else if cmd == "revoke" { // 撤回最近一条消息
-		h.handleRevokeLastMessage()
-	} else if cmd == "repeat" { // 重复最近一条消息
-		h.handleRepeatLastMessage()
-	} else if cmd == "topic" { // 获取当前话题
-		h.logger.Log(h.oldTopic.Discussing)
-	} else if strings.HasPrefix(cmd, prefixChangeTopic) {
-		h.handleTopicView(strings.TrimPrefix(cmd, prefixChangeTopic))
-	} else if strings.HasPrefix(cmd, "sb+") { // 屏蔽发言
-		sb := strings.TrimPrefix(cmd, "sb+")
-		h.sbMap[sb] = struct{}{}
-	} else if strings.HasPrefix(cmd, "sb-") { // 取消屏蔽
-		sb := strings.TrimPrefix(cmd, "sb-")
-		delete(h.sbMap, sb)
-	} else {
-		h.logger.Logf("无效指令：%s", cmd)
-	}
-}
-
-func (h *Handler) handleReceiveRedPacket(gesture string) {
-	var red *WsMsgReply
-	switch gesture {
-	case "0":
-		red = h.red
-	case "1", "2", "3":
-		red = h.gesture
-	case "4":
-		red = h.heartbeat
-	case "5":
-		red = h.own
-	}
-	if red == nil {
+// HandleInput 处理终端输入的指令
+func (h *Handler) HandleInput(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
 		return
 	}
-
-	result, err := h.sdk.OpenRedPacket(red.OId, gesture)
-	if err != nil {
-		h.logger.Logf("打开红包%s失败 %s", red.OId, err)
-		return
+	if err := h.commands.Exec(line, h.display); err != nil {
+		h.display.Print(err.Error())
 	}
-	h.logger.Log(result)
 }
 
-func (h *Handler) handleRevokeLastMessage() {
-	if h.lastest == nil {
-		h.logger.Log("您最近还没有讲话")
-		return
-	}
-	if err := h.sdk.RevokeMsg(h.lastest.OId); err != nil {
-		h.logger.Log(err.Error())
-		return
-	}
-	h.logger.Log("撤回消息操作成功")
+// Commands 接收端支持的指令
+func (h *Handler) Commands() *command.Registry {
+	return h.commands
 }
 
-func (h *Handler) handleRepeatLastMessage() {
-	if h.cache == nil || len(h.cache) == 0 {
-		return
+// changeTopic 记录在线消息中的话题 第一次收到和话题没有变化时返回false 不需要展示
+func (h *Handler) changeTopic(msg *WsMsgReply) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.oldTopic == nil {
+		h.oldTopic = msg
+		return false
 	}
-	msg := h.cache[len(h.cache)-1]
-	if err := h.sdk.SendMsg(msg.Md); err != nil {
-		h.logger.Log(err.Error())
+	if msg.Msg() == h.oldTopic.Msg() {
+		return false
 	}
+	h.oldTopic = msg
+	return true
 }
 
-func (h *Handler) handleTopicView(msg string) {
-	msg = fmt.Sprintf("%s\n*`# %s #`*", msg, h.oldTopic.Discussing)
-	if err := h.sdk.SendMsg(msg); err != nil {
-		h.logger.Log(err.Error())
+func (h *Handler) topic() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.oldTopic == nil {
+		return ""
 	}
+	return h.oldTopic.Discussing
 }
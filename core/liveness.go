@@ -1,10 +1,11 @@
 package core
 
 import (
-	"fmt"
 	"math"
 	"sync"
 	"time"
+
+	"fishpi/logger"
 )
 
 type lnClient struct {
@@ -17,10 +18,11 @@ type lnClient struct {
 	lastUpdateTime     time.Time // 上次更新时间
 	updateLivenessFunc func(float64)
 
-	mu sync.Mutex
+	display logger.Display
+	mu      sync.Mutex
 }
 
-func NewLnClient(liveness float64, updateLivenessFunc func(float64)) *lnClient {
+func NewLnClient(liveness float64, updateLivenessFunc func(float64), display logger.Display) *lnClient {
 	ln := &lnClient{
 		liveness: liveness,
 		inc:      1.67,
@@ -28,6 +30,7 @@ func NewLnClient(liveness float64, updateLivenessFunc func(float64)) *lnClient {
 
 		lastUpdateTime:     time.Now().Add(-30 * time.Second),
 		updateLivenessFunc: updateLivenessFunc,
+		display:            display,
 	}
 	ln.calcValidTime()
 
@@ -39,7 +42,7 @@ func (ln *lnClient) calcValidTime() {
 	now := time.Now()
 	ln.startTime = time.Date(now.Year(), now.Month(), now.Day(), 8, 0, 0, 0, now.Location())
 	ln.endTime = time.Date(now.Year(), now.Month(), now.Day(), 19, 30, 0, 0, now.Location())
-	fmt.Printf("今日活跃时间：%s ~ %s\n", ln.startTime.Format("2006-01-02 15:04:05"), ln.endTime.Format("2006-01-02 15:04:05"))
+	ln.display.Printf("今日活跃时间：%s ~ %s", ln.startTime.Format("2006-01-02 15:04:05"), ln.endTime.Format("2006-01-02 15:04:05"))
 }
 
 func (ln *lnClient) isContinue() bool {
@@ -86,9 +89,9 @@ func (ln *lnClient) Say() {
 		t := (time.Duration(math.Ceil(need*ln.interval)) * time.Second).Minutes()
 
 		if ln.liveness < 100 {
-			fmt.Printf("还差(%.f/%.f) 预计还需%.f分钟\n", need, all, t)
+			ln.display.Printf("还差(%.f/%.f) 预计还需%.f分钟", need, all, t)
 		} else {
-			fmt.Println("你已经满了 快去code吧")
+			ln.display.Print("你已经满了 快去code吧")
 		}
 	}()
 }
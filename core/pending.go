@@ -0,0 +1,225 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"fishpi/command"
+	"fishpi/logger"
+)
+
+// maxPending 最多记住的未领完红包数量 超过时丢弃最早的
+const maxPending = 20
+
+// pendingPacket 一个还没有领完的红包
+type pendingPacket struct {
+	msg   *WsMsgReply
+	got   int
+	count int
+}
+
+func (p *pendingPacket) String() string {
+	rp := p.msg.JsonInfo
+	special := ""
+	if rp.Type == RedPacketTypeSpecify {
+		special = " 给" + rp.Recivers
+	}
+	return fmt.Sprintf("%s %s(%s)的%s%s %d积分(%d/%d) %s", p.msg.Time[11:], p.msg.UserNickname, p.msg.UserName, rp.TypeName(), special, rp.Money, p.got, p.count, rp.Msg)
+}
+
+// pendingPackets 收到的还没有领完的红包 按收到的顺序排列 根据红包领取消息更新
+type pendingPackets struct {
+	mu   sync.Mutex
+	list []*pendingPacket
+}
+
+// add 收到新的红包
+func (p *pendingPackets) add(msg *WsMsgReply) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.list = append(p.list, &pendingPacket{msg: msg, got: msg.JsonInfo.Got, count: msg.JsonInfo.Count})
+	if len(p.list) > maxPending {
+		p.list = p.list[len(p.list)-maxPending:]
+	}
+}
+
+// update 根据红包领取消息更新领取数量 领完后删除
+func (p *pendingPackets) update(status *WsMsgReply) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, v := range p.list {
+		if v.msg.OId != status.OId {
+			continue
+		}
+		v.got, v.count = status.Got, status.Count
+		if v.count > 0 && v.got >= v.count {
+			p.list = append(p.list[:i:i], p.list[i+1:]...)
+		}
+		return
+	}
+}
+
+// remove 打开后不再显示
+func (p *pendingPackets) remove(oId string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, v := range p.list {
+		if v.msg.OId == oId {
+			p.list = append(p.list[:i:i], p.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// typeOf 红包的类型 不在其中时返回空字符串
+func (p *pendingPackets) typeOf(oId string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, v := range p.list {
+		if v.msg.OId == oId {
+			return v.msg.JsonInfo.Type
+		}
+	}
+	return ""
+}
+
+// get 按packets指令列出的编号获取红包 编号从1开始
+func (p *pendingPackets) get(n int) (*WsMsgReply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n < 1 || n > len(p.list) {
+		return nil, fmt.Errorf("红包编号应当在1到%d之间：%d", len(p.list), n)
+	}
+	return p.list[n-1].msg, nil
+}
+
+func (p *pendingPackets) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.list)
+}
+
+// latest 最近收到的指定类型的红包 没有时返回nil
+func (p *pendingPackets) latest(types ...string) *WsMsgReply {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := len(p.list) - 1; i >= 0; i-- {
+		msg := p.list[i].msg
+		for _, t := range types {
+			if msg.JsonInfo.Type == t {
+				return msg
+			}
+		}
+	}
+	return nil
+}
+
+func (p *pendingPackets) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.list) == 0 {
+		return "没有未领完的红包"
+	}
+	lines := make([]string, 0, len(p.list))
+	for i, v := range p.list {
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, v))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// openType 按类型打开最近的红包 gesture为0-5
+func (p *pendingPackets) openType(sdk *Sdk, gesture string, out logger.Display) error {
+	var types []string
+	switch gesture {
+	case "0":
+		types = []string{RedPacketTypeRandom, RedPacketTypeAverage}
+	case "1", "2", "3":
+		types = []string{RedPacketTypeRockPaperScissors}
+	case "4":
+		types = []string{RedPacketTypeHeartbeat}
+	case "5":
+		types = []string{RedPacketTypeSpecify}
+	default:
+		return fmt.Errorf("红包类型应当是0-5：%s", gesture)
+	}
+	red := p.latest(types...)
+	if red == nil {
+		return errors.New("最近没有这种红包")
+	}
+	return p.open(sdk, red, gesture, out)
+}
+
+// open 打开红包 打开后从未领完的红包中删除
+func (p *pendingPackets) open(sdk *Sdk, red *WsMsgReply, gesture string, out logger.Display) error {
+	result, err := sdk.OpenRedPacketResult(red.OId, red.JsonInfo.Type, gesture)
+	if err != nil {
+		return fmt.Errorf("打开红包%s失败 %w", red.OId, err)
+	}
+	p.remove(red.OId)
+	out.Print(result.String())
+	return nil
+}
+
+// packetCommands 查看和打开未领完的红包的指令 接收端和simple模式共用
+func packetCommands(r *command.Registry, sdk *Sdk, packets *pendingPackets) {
+	r.Register(
+		&command.Command{
+			Name:    "packets",
+			Aliases: []string{"p"},
+			Help:    "查看还没有领完的红包 编号用于open指令",
+			Run: func(c *command.Context) error {
+				c.Out.Print(packets.String())
+				return nil
+			},
+		},
+		&command.Command{
+			Name:  "open",
+			Usage: "[编号] [出拳]",
+			Help:  "打开packets列出的红包 不填编号时打开最近的红包 猜拳红包可以指定出拳 1-石头 2-剪刀 3-布 默认随机",
+			Run: func(c *command.Context) error {
+				n := packets.len()
+				if n == 0 {
+					return errors.New("没有未领完的红包")
+				}
+				if c.Arg(0) != "" {
+					var err error
+					if n, err = strconv.Atoi(c.Arg(0)); err != nil {
+						return fmt.Errorf("红包编号应当是数字：%s", c.Arg(0))
+					}
+				}
+				red, err := packets.get(n)
+				if err != nil {
+					return err
+				}
+				gesture := c.Arg(1)
+				switch gesture {
+				case "":
+					gesture = "0"
+				case "1", "2", "3":
+				default:
+					return fmt.Errorf("出拳应当是1-3：%s", gesture)
+				}
+				return packets.open(sdk, red, gesture, c.Out)
+			},
+		},
+		&command.Command{
+			Name:    "open-type",
+			Aliases: []string{"0", "1", "2", "3", "4", "5"},
+			Usage:   "{0-5}",
+			Help:    "按类型打开最近的红包 0-普通红包(拼手气 平分) 1-3猜拳红包(石头 剪刀 布) 4-心跳红包 5-专属红包",
+			Complete: func(args []string) []string {
+				return []string{"0", "1", "2", "3", "4", "5"}
+			},
+			Run: func(c *command.Context) error {
+				gesture := c.Name
+				if c.Name == "open-type" {
+					gesture = c.Arg(0)
+				}
+				return packets.openType(sdk, gesture, c.Out)
+			},
+		},
+	)
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPendingPackets(t *testing.T) {
+	var p pendingPackets
+	packet := func(oId, typ string, count int) *WsMsgReply {
+		return &WsMsgReply{Type: WsMsgTypeMsg, OId: oId, Time: "2024-05-01 12:00:00", UserName: "alice", JsonInfo: &JsonInfo{MsgType: JsonMsgTypeRedPacket, Type: typ, Money: 100, Count: count}}
+	}
+	p.add(packet("1", RedPacketTypeRandom, 2))
+	p.add(packet("2", RedPacketTypeHeartbeat, 5))
+	p.add(packet("3", RedPacketTypeAverage, 3))
+
+	if red := p.latest(RedPacketTypeRandom, RedPacketTypeAverage); red == nil || red.OId != "3" {
+		t.Fatalf("latest %v", red)
+	}
+
+	// 领完后删除
+	p.update(&WsMsgReply{Type: WsMsgTypeRedPacketStatus, OId: "1", Count: 2, Got: 1})
+	if !strings.Contains(p.String(), "(1/2)") {
+		t.Errorf("got not updated: %s", p.String())
+	}
+	p.update(&WsMsgReply{Type: WsMsgTypeRedPacketStatus, OId: "1", Count: 2, Got: 2})
+	if p.len() != 2 {
+		t.Fatalf("exhausted packet not removed: %s", p.String())
+	}
+	if red, err := p.get(1); err != nil || red.OId != "2" {
+		t.Fatalf("get(1) = %v %v", red, err)
+	}
+	if _, err := p.get(3); err == nil {
+		t.Error("越界的编号应当报错")
+	}
+
+	p.remove("2")
+	if red, _ := p.get(1); red.OId != "3" || p.latest(RedPacketTypeHeartbeat) != nil {
+		t.Errorf("remove failed: %s", p.String())
+	}
+}
+
+func TestPendingPacketType(t *testing.T) {
+	var p pendingPackets
+	p.add(&WsMsgReply{OId: "1", JsonInfo: &JsonInfo{Type: RedPacketTypeHeartbeat, Count: 2}})
+	if typ := p.typeOf("1"); typ != RedPacketTypeHeartbeat {
+		t.Errorf("type %q", typ)
+	}
+	if typ := p.typeOf("2"); typ != "" {
+		t.Errorf("unknown packet type %q", typ)
+	}
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"fishpi/archive"
+	"fishpi/command"
+)
+
+// quoteHeader 网页端引用消息的标题 ##### 引用 @user [↩](https://fishpi.cn/cr#chatroom{oId} "跳转至原消息")
+var quoteHeader = regexp.MustCompile(`^#####\s+引用\s+@(\S+)(?:\s+\[↩\]\([^)#]*#chatroom(\d+)[^)]*\))?`)
+
+// quoteSummaryLen 紧凑展示时引用内容的最大长度
+const quoteSummaryLen = 30
+
+// Quote 消息中引用的另一条消息
+type Quote struct {
+	UserName string // 被引用的用户
+	OId      string // 被引用的消息 旧格式没有链接时为空
+	Text     string // 被引用的内容 不包含更早的引用
+}
+
+// Summary 紧凑展示的引用 例如 ↩ @alice: 摸鱼吗
+func (q *Quote) Summary() string {
+	text := strings.Join(strings.Fields(q.Text), " ")
+	if utf8.RuneCountInString(text) > quoteSummaryLen {
+		text = string([]rune(text)[:quoteSummaryLen]) + "…"
+	}
+	return fmt.Sprintf("↩ @%s: %s", q.UserName, text)
+}
+
+// ParseQuote 从Markdown中拆出引用 返回引用和去掉引用后的正文 没有引用时quote为nil
+func ParseQuote(md string) (quote *Quote, body string) {
+	lines := strings.Split(md, "\n")
+	var rest, quoted []string
+	inQuote := false
+	for _, line := range lines {
+		if quote == nil {
+			if m := quoteHeader.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				quote = &Quote{UserName: m[1], OId: m[2]}
+				inQuote = true
+				continue
+			}
+		}
+		if inQuote {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" {
+				continue
+			}
+			if text, ok := strings.CutPrefix(trimmed, ">"); ok {
+				text = strings.TrimSpace(text)
+				// 原消息中更早的引用不再展示
+				if !strings.HasPrefix(text, ">") && !quoteHeader.MatchString(text) {
+					quoted = append(quoted, text)
+				}
+				continue
+			}
+			inQuote = false
+		}
+		rest = append(rest, line)
+	}
+	if quote != nil {
+		quote.Text = strings.TrimSpace(strings.Join(quoted, "\n"))
+	}
+	return quote, strings.TrimSpace(strings.Join(rest, "\n"))
+}
+
+// QuoteMsg 生成与网页端一致的引用消息 text为回复内容
+func (c *Sdk) QuoteMsg(msg *WsMsgReply, text string) string {
+	original := msg.Md
+	if original == "" {
+		original = msg.Content
+	}
+	_, original = ParseQuote(original)
+
+	var sb strings.Builder
+	sb.WriteString(text)
+	fmt.Fprintf(&sb, "\n\n##### 引用 @%s [↩](%s \"跳转至原消息\")\n\n", msg.UserName, c.api.chatroomMessage(msg.OId))
+	for _, line := range strings.Split(original, "\n") {
+		sb.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// replyCommand 回复指定的消息 find按oId查找原消息
+func replyCommand(sdk *Sdk, find func(oId string) *WsMsgReply, said func()) *command.Command {
+	return &command.Command{
+		Name:    "reply",
+		Usage:   "{oId} {内容}",
+		Help:    "引用并回复一条消息 oId可以通过search指令或者simple模式的消息菜单获取",
+		MinArgs: 2,
+		Run: func(c *command.Context) error {
+			oId := c.Arg(0)
+			msg := find(oId)
+			if msg == nil {
+				return fmt.Errorf("没有找到消息：%s", oId)
+			}
+			text := strings.TrimSpace(strings.TrimPrefix(c.Raw, oId))
+			if err := sdk.SendMsg(sdk.QuoteMsg(msg, text)); err != nil {
+				return err
+			}
+			if said != nil {
+				said()
+			}
+			return nil
+		},
+	}
+}
+
+// findMessage 先在缓存中查找消息 找不到时查找存档
+func findMessage(cache *msgCache, arc *archive.Archive, oId string) *WsMsgReply {
+	if msg := cache.find(oId); msg != nil {
+		return msg
+	}
+	if arc == nil {
+		return nil
+	}
+	r, err := arc.Get(oId)
+	if err != nil || r.Type != archive.TypeMsg {
+		return nil
+	}
+	return &WsMsgReply{Type: WsMsgTypeMsg, OId: r.OId, UserName: r.UserName, UserNickname: r.Nickname, Md: r.Content}
+}
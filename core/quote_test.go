@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+)
+
+func TestQuote(t *testing.T) {
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	api, _ := NewApi("https://fishpi.cn")
+	sdk := NewSdk(api, "test", "key", "me", l)
+
+	original := &WsMsgReply{Type: WsMsgTypeMsg, OId: "1700000000001", UserName: "alice", Md: "摸鱼吗\n\n##### 引用 @bob [↩](https://fishpi.cn/cr#chatroom1700000000000 \"跳转至原消息\")\n\n> 上班了"}
+	md := sdk.QuoteMsg(original, "摸")
+	want := "摸\n\n##### 引用 @alice [↩](https://fishpi.cn/cr#chatroom1700000000001 \"跳转至原消息\")\n\n> 摸鱼吗"
+	if md != want {
+		t.Fatalf("QuoteMsg\n%q\nwant\n%q", md, want)
+	}
+
+	// 网页端回复引用时会嵌套更早的引用
+	md += "\n>\n> ##### 引用 @bob [↩](https://fishpi.cn/cr#chatroom1700000000000 \"跳转至原消息\")\n>\n> > 上班了"
+	q, body := ParseQuote(md)
+	if q == nil || q.UserName != "alice" || q.OId != "1700000000001" || q.Text != "摸鱼吗" || body != "摸" {
+		t.Fatalf("ParseQuote = %+v %q", q, body)
+	}
+
+	reply := &WsMsgReply{Type: WsMsgTypeMsg, Time: "2024-05-01 12:00:00", UserName: "me", UserNickname: "我", Md: md, Client: "Golang"}
+	reply.Parse()
+	if got := reply.Msg(); got != "12:00:00 我(me): 摸 [↩ @alice: 摸鱼吗](Golang)" {
+		t.Errorf("Msg() = %q", got)
+	}
+
+	if q, body = ParseQuote("没有引用\n> 普通的引用块"); q != nil || !strings.HasPrefix(body, "没有引用") {
+		t.Errorf("ParseQuote without quote = %+v %q", q, body)
+	}
+}
+
+func TestReplyWhileReceiving(t *testing.T) {
+	var mu sync.Mutex
+	sent := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chat-room/more":
+			fmt.Fprint(w, `{"code":0,"data":[]}`)
+		case "/chat-room/send":
+			mu.Lock()
+			sent++
+			mu.Unlock()
+			fmt.Fprint(w, `{"code":0}`)
+		}
+	}))
+	defer srv.Close()
+
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	api, _ := NewApi(srv.URL)
+	sdk := NewSdk(api, "test", "key", "me", l)
+	display := new(testDisplay)
+	h := NewHandler(5, sdk, eventHandler.NewBus("test", l), display, l)
+
+	msg := func(oId string) []byte {
+		return []byte(fmt.Sprintf(`{"type":"msg","oId":"%s","time":"2024-05-01 12:00:00","userName":"alice","md":"摸鱼 %s"}`, oId, oId))
+	}
+	h.HandleMsg(msg("1"))
+
+	// 接收消息和执行指令在不同的goroutine中 使用-race运行
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 2; i < 200; i++ {
+			h.HandleMsg(msg(strconv.Itoa(i)))
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		_ = h.commands.Exec("reply "+strconv.Itoa(i*10+1)+" 摸", display)
+		_ = h.commands.Exec("repeat", display)
+	}
+	<-done
+
+	if err := h.commands.Exec("reply 199 摸", display); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if sent < 21 {
+		t.Errorf("sent %d", sent)
+	}
+}
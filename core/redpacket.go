@@ -0,0 +1,34 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"fishpi/redpacket"
+)
+
+// OpenResult 打开红包的结果 同时是账本中的记录
+type OpenResult struct {
+	redpacket.Entry
+	Msg string // 红包祝福语
+}
+
+func (r *OpenResult) String() string {
+	receiveResult := "但是没有领取到欸"
+	if r.Received {
+		if r.Gain < 0 {
+			receiveResult = fmt.Sprintf("血亏 损失到了%d积分", -r.Gain)
+		} else if r.Gain > 0 {
+			receiveResult = fmt.Sprintf("真牛 领取到了%d积分", r.Gain)
+		} else {
+			receiveResult = "你抢了个寂寞"
+		}
+	}
+	receiveList := make([]string, 0, len(r.Who))
+	for _, v := range r.Who {
+		receiveList = append(receiveList, fmt.Sprintf("- %s %s 抢到了%d积分", v.Time, v.UserName, v.UserMoney))
+	}
+	//receiveResult += fmt.Sprintf("他出的%s", reply.Info.GestureName()) // 接口并未返回对方出拳 但是网页有
+
+	return fmt.Sprintf("你打开%s发的红包(%d/%d) %s\n 领取情况：\n%s\n\n%s", r.Sender, r.Got, r.Count, receiveResult, strings.Join(receiveList, "\n"), r.Msg)
+}
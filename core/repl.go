@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fishpi/archive"
+	"fishpi/block"
+	"fishpi/command"
+	"fishpi/logger"
+)
+
+// replPrefix 读写合一模式中指令的前缀 两个前缀开头的输入作为普通消息发送
+const replPrefix = "/"
+
+// Repl 读写合一的聊天室 Handler负责展示收到的消息 普通输入作为消息发送 以/开头的输入作为指令
+type Repl struct {
+	handler  *Handler
+	client   *Client
+	commands *command.Registry
+
+	display logger.Display
+}
+
+// NewRepl h和c应当使用同一个聊天室命名空间
+func NewRepl(h *Handler, c *Client, display logger.Display) *Repl {
+	r := &Repl{
+		handler:  h,
+		client:   c,
+		commands: command.NewRegistry(),
+		display:  display,
+	}
+	env := &commandEnv{sdk: c.sdk, eh: c.eh, archive: func() *archive.Archive { return h.archive }, blocks: func() *block.List { return h.blocks }, topic: h.topic, said: c.said}
+	env.register(r.commands)
+	h.handlerCommands(r.commands, c.said)
+	return r
+}
+
+// Start 初始化活跃度统计
+func (r *Repl) Start() {
+	r.client.Start()
+}
+
+// Commands 读写合一模式支持的指令
+func (r *Repl) Commands() *command.Registry {
+	return r.commands
+}
+
+// HandleInput 以/开头的输入作为指令 //开头的输入去掉一个/后作为消息发送
+func (r *Repl) HandleInput(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	var err error
+	if cmd, ok := strings.CutPrefix(line, replPrefix); ok && !strings.HasPrefix(cmd, replPrefix) {
+		err = r.commands.Exec(cmd, r.display)
+		if errors.Is(err, command.ErrUnknown) {
+			err = fmt.Errorf("%w 输入/help查看所有指令", err)
+		}
+	} else {
+		err = r.client.env.send(strings.TrimPrefix(line, replPrefix))
+	}
+	if err != nil {
+		r.display.Print(err.Error())
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+)
+
+type testDisplay struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (d *testDisplay) Printf(format string, a ...interface{}) {
+	d.Print(fmt.Sprintf(format, a...))
+}
+
+func (d *testDisplay) Print(msg string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lines = append(d.lines, msg)
+}
+
+func TestRepl(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chat-room/more":
+			fmt.Fprint(w, `{"code":0,"data":[]}`)
+		case "/chat-room/send":
+			var data sendMsgData
+			_ = json.NewDecoder(r.Body).Decode(&data)
+			sent = append(sent, data.Content)
+			fmt.Fprint(w, `{"code":0}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	api, _ := NewApi(srv.URL)
+	sdk := NewSdk(api, "test", "key", "me", l)
+	eh := eventHandler.NewBus("test", l)
+	display := new(testDisplay)
+
+	h := NewHandler(20, sdk, eh, display, l)
+	repl := NewRepl(h, NewClient(sdk, eh, display, l), display)
+
+	repl.HandleInput("摸鱼")
+	repl.HandleInput("//help")
+	repl.HandleInput("/topic 新话题")
+	want := []string{"摸鱼", "/help", "[setdiscuss]新话题[/setdiscuss]"}
+	if strings.Join(sent, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", sent, want)
+	}
+
+	repl.HandleInput("/unknown")
+	repl.HandleInput("/help open")
+	if len(display.lines) != 2 || !strings.Contains(display.lines[0], "无效指令") || !strings.HasPrefix(display.lines[1], "open [编号] [出拳]") {
+		t.Fatalf("display %q", display.lines)
+	}
+}
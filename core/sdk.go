@@ -10,8 +10,10 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"fishpi/logger"
+	"fishpi/redpacket"
 )
 
 type Sdk struct {
@@ -19,6 +21,7 @@ type Sdk struct {
 	ua       string
 	apiKey   string
 	username string
+	ledger   *redpacket.Ledger // 红包账本 未设置时不记录
 
 	logger logger.Logger
 }
@@ -30,7 +33,7 @@ func NewSdk(api *Api, userAgent, apiKey, username string, logger logger.Logger)
 
 		apiKey:   apiKey,
 		username: username,
-		logger:   logger,
+		logger:   logger.Named("sdk"),
 	}
 
 	return c
@@ -53,6 +56,19 @@ func (c *Sdk) GetWsUrl() (string, error) {
 	return reply.Data, nil
 }
 
+// GetChatChannelUrl 获取与toUser私聊的ws地址
+func (c *Sdk) GetChatChannelUrl(toUser string) (string, error) {
+	if toUser == "" {
+		return "", errors.New("私聊对象不能为空")
+	}
+	return c.api.chatChanel(c.apiKey, toUser), nil
+}
+
+// GetUserChannelUrl 获取用户通知的ws地址
+func (c *Sdk) GetUserChannelUrl() (string, error) {
+	return c.api.userChannel(c.apiKey), nil
+}
+
 // User 获取自己的信息
 func (c *Sdk) User() (string, error) {
 	body, err := c.get(c.api.user())
@@ -118,6 +134,25 @@ func (c *Sdk) ChatRecordPage(page int) ([]*ChatRecordPageData, error) {
 	return reply.Data, nil
 }
 
+// recentRedPacket 在最近一页聊天记录中查找红包消息 找不到时返回nil
+func (c *Sdk) recentRedPacket(oId string) (*WsMsgReply, error) {
+	data, err := c.ChatRecordPage(1)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range data {
+		if v.OId != oId {
+			continue
+		}
+		msg := &WsMsgReply{Type: WsMsgTypeMsg, OId: v.OId, UserName: v.UserName, Content: v.Content}
+		if msg.Parse(); msg.IsRedPacketMsg() {
+			return msg, nil
+		}
+		break
+	}
+	return nil, nil
+}
+
 // UserInfo 获取用户信息
 func (c *Sdk) UserInfo(username string) string {
 	body, err := c.get(c.api.userInfo(username))
@@ -230,7 +265,8 @@ func (c *Sdk) SendBreezeMoon(msg string) error {
 	return nil
 }
 
-func (c *Sdk) BreezeMoonList(msg string) error {
+// BreezeMoonList 获取明月清风列表 msg格式为 {size-page}
+func (c *Sdk) BreezeMoonList(msg string) (string, error) {
 	params := strings.Split(msg, "-")
 
 	var e error
@@ -252,24 +288,24 @@ func (c *Sdk) BreezeMoonList(msg string) error {
 
 	body, err := c.get(c.api.breezeMoonList(page, size))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	var reply breezeMoonReply
 	if err = json.Unmarshal(body, &reply); err != nil {
-		return err
+		return "", err
 	}
 	if reply.Code != 0 {
-		return fmt.Errorf("get breezeMoon list error, code: %d", reply.Code)
+		return "", fmt.Errorf("get breezeMoon list error, code: %d", reply.Code)
 	}
-	fmt.Println(reply.String())
 
-	return nil
+	return reply.String(), nil
 }
 
-func (c *Sdk) BreezeMoonUser(msg string) error {
+// BreezeMoonUser 获取用户的明月清风 msg格式为 {username-size-page}
+func (c *Sdk) BreezeMoonUser(msg string) (string, error) {
 	if msg == "" {
-		return errors.New("用户名不能为空")
+		return "", errors.New("用户名不能为空")
 	}
 	params := strings.Split(msg, "-")
 
@@ -296,19 +332,18 @@ func (c *Sdk) BreezeMoonUser(msg string) error {
 
 	body, err := c.get(c.api.breezeMoonUser(name, page, size))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	var reply breezeMoonUserReply
 	if err = json.Unmarshal(body, &reply); err != nil {
-		return err
+		return "", err
 	}
 	if reply.Code != 0 {
-		return fmt.Errorf("get breezeMoon user %s error, code: %d", name, reply.Code)
+		return "", fmt.Errorf("get breezeMoon user %s error, code: %d", name, reply.Code)
 	}
-	fmt.Println(reply.String())
 
-	return nil
+	return reply.String(), nil
 }
 
 // RevokeMsg 聊天室撤回消息
@@ -328,12 +363,22 @@ func (c *Sdk) RevokeMsg(oId string) error {
 		return err
 	}
 	if reply.Code != 0 {
-		return fmt.Errorf("revoke msg error, code: %d, msg: %s", reply.Code, reply.Msg)
+		return &RevokeError{Code: reply.Code, Msg: reply.Msg}
 	}
 
 	return nil
 }
 
+// RevokeError 服务器拒绝撤回 例如超过了撤回时间或者不是自己的消息
+type RevokeError struct {
+	Code int
+	Msg  string
+}
+
+func (e *RevokeError) Error() string {
+	return fmt.Sprintf("revoke msg error, code: %d, msg: %s", e.Code, e.Msg)
+}
+
 // PointTransfer 积分转账
 func (c *Sdk) PointTransfer(username string, amount int, momo string) ([]byte, error) {
 	data := &pointTransferData{
@@ -359,8 +404,24 @@ func (c *Sdk) PointTransfer(username string, amount int, momo string) ([]byte, e
 	return body, nil
 }
 
-// OpenRedPacket 打开红包
-func (c *Sdk) OpenRedPacket(oId string, gesture string) (string, error) {
+// OpenRedPacket 打开红包 返回展示给用户的结果 typ为空时从最近的聊天记录中查找红包类型 用于bridge等调用方
+func (c *Sdk) OpenRedPacket(oId, typ, gesture string) (string, error) {
+	if typ == "" {
+		if msg, err := c.recentRedPacket(oId); err != nil {
+			c.logger.Warn("查找红包类型失败", "oId", oId, "err", err)
+		} else if msg != nil {
+			typ = msg.JsonInfo.Type
+		}
+	}
+	result, err := c.OpenRedPacketResult(oId, typ, gesture)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// OpenRedPacketResult 打开红包 typ为红包类型 设置了账本时记录结果
+func (c *Sdk) OpenRedPacketResult(oId, typ, gesture string) (*OpenResult, error) {
 	data := &openRedPacketData{
 		ApiKey: c.apiKey,
 		OId:    oId,
@@ -379,33 +440,50 @@ func (c *Sdk) OpenRedPacket(oId string, gesture string) (string, error) {
 
 	body, err := c.post(c.api.openRedPacket(), data)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	var reply openRedPacketReply
 	if err = json.Unmarshal(body, &reply); err != nil {
-		return "", err
+		return nil, err
+	}
+	if reply.Info == nil {
+		return nil, fmt.Errorf("打开红包失败：%s", body)
 	}
 
-	receiveResult := "但是没有领取到欸"
-	var receiveList []string
+	result := &OpenResult{
+		Entry: redpacket.Entry{
+			Time:   time.Now(),
+			OId:    oId,
+			Type:   typ,
+			Sender: reply.Info.UserName,
+			Count:  reply.Info.Count,
+			Got:    reply.Info.Got,
+		},
+		Msg: reply.Info.Msg,
+	}
 	for _, v := range reply.Who {
 		if v.UserName == c.username {
-			if v.UserMoney < 0 {
-				receiveResult = fmt.Sprintf("血亏 损失到了%d积分", -v.UserMoney)
-			} else if v.UserMoney > 0 {
-				receiveResult = fmt.Sprintf("真牛 领取到了%d积分", v.UserMoney)
-			} else {
-				receiveResult = "你抢了个寂寞"
-			}
+			result.Received = true
+			result.Gain = v.UserMoney
 		}
-		receiveList = append(receiveList, fmt.Sprintf("- %s %s 抢到了%d积分", v.Time, v.UserName, v.UserMoney))
+		result.Who = append(result.Who, redpacket.Who{UserName: v.UserName, UserMoney: v.UserMoney, Time: v.Time})
+	}
+	if err = c.ledger.Add(&result.Entry); err != nil {
+		c.logger.Warn("红包记录写入账本失败", "oId", oId, "err", err)
 	}
-	//receiveResult += fmt.Sprintf("他出的%s", reply.Info.GestureName()) // 接口并未返回对方出拳 但是网页有
+	return result, nil
+}
 
-	result := fmt.Sprintf("你打开%s发的红包(%d/%d) %s\n 领取情况：\n%s\n\n%s", reply.Info.UserName, reply.Info.Got, reply.Info.Count, receiveResult, strings.Join(receiveList, "\n"), reply.Info.Msg)
+// SetLedger 设置红包账本 打开的红包都会记录
+func (c *Sdk) SetLedger(l *redpacket.Ledger) *Sdk {
+	c.ledger = l
+	return c
+}
 
-	return result, nil
+// Ledger 红包账本 未设置时为nil
+func (c *Sdk) Ledger() *redpacket.Ledger {
+	return c.ledger
 }
 
 func (c *Sdk) GetApiKey() string {
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fishpi/logger"
+	"fishpi/redpacket"
+)
+
+func TestOpenRedPacketType(t *testing.T) {
+	lookups := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chat-room/more":
+			lookups++
+			content, _ := json.Marshal(map[string]any{"msgType": "redPacket", "type": RedPacketTypeHeartbeat, "money": 100, "count": 5})
+			body, _ := json.Marshal(map[string]any{"code": 0, "data": []map[string]any{{"oId": "1", "userName": "alice", "content": string(content)}}})
+			w.Write(body)
+		case "/chat-room/red-packet/open":
+			fmt.Fprint(w, `{"code":0,"who":[{"userName":"me","userMoney":10}],"info":{"count":5,"got":1,"msg":"","userName":"alice"}}`)
+		}
+	}))
+	defer srv.Close()
+
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	api, _ := NewApi(srv.URL)
+	ledger := redpacket.NewLedger(filepath.Join(t.TempDir(), "redpackets.jsonl"), l)
+	sdk := NewSdk(api, "test", "key", "me", l).SetLedger(ledger)
+
+	// 已知类型时不查找 未知时从聊天记录中查找 不在最近的聊天记录中时为空
+	for _, v := range [][2]string{{"1", ""}, {"2", ""}, {"3", RedPacketTypeRandom}} {
+		if _, err := sdk.OpenRedPacket(v[0], v[1], ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := ledger.Entries(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Type != RedPacketTypeHeartbeat || entries[1].Type != "" || entries[2].Type != RedPacketTypeRandom {
+		t.Errorf("unexpected entries: %+v %+v %+v", entries[0], entries[1], entries[2])
+	}
+	if lookups != 2 {
+		t.Errorf("lookups %d", lookups)
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"fishpi/archive"
+	"fishpi/block"
+)
+
+const (
+	searchContext    = 1  // 每条结果前后展示的消息数量
+	searchContextLen = 60 // 上下文消息最多展示的字数
+)
+
+const searchHelp = `search {关键词} [user:用户名] [type:msg|revoke|redPacketStatus|barrager|discussChanged] [from:2006-01-02] [to:2006-01-02] [limit:20]
+多个关键词需要同时包含 日期也可以写成 2006-01-02T15:04`
+
+// Search 搜索本地存档的聊天记录 返回带上下文的结果 oId可以用于引用和回复 被屏蔽的记录不展示
+func Search(arc *archive.Archive, blocks *block.List, args string) string {
+	if arc == nil {
+		return "聊天记录存档未开启 存档只在接收端打开 可以使用 -repl 或者 -msg -ws 在同一个进程中运行"
+	}
+	if strings.TrimSpace(args) == "" {
+		return searchHelp
+	}
+	q, err := archive.ParseQuery(args)
+	if err != nil {
+		return fmt.Sprintf("搜索条件错误 %s\n%s", err, searchHelp)
+	}
+	q.Exclude = func(r *archive.Record) bool {
+		return blocks.Block(&block.Message{Type: r.Type, UserName: r.UserName, Client: r.Client, Content: r.Content})
+	}
+	records, err := arc.Search(q)
+	if err != nil {
+		return fmt.Sprintf("搜索失败 %s", err)
+	}
+	if len(records) == 0 {
+		return "没有找到相关记录"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "找到%d条记录", len(records))
+	for _, r := range records {
+		id := r.OId
+		if id == "" {
+			id = r.Key
+		}
+		fmt.Fprintf(&sb, "\n\n%s oId:%s", r.Time.Format("2006-01-02"), id)
+
+		before, after, err := arc.Around(r, searchContext, q.Exclude)
+		if err != nil {
+			fmt.Fprintf(&sb, "\n  获取上下文失败 %s", err)
+		}
+		for _, c := range before {
+			sb.WriteString("\n  " + searchLine(c, searchContextLen))
+		}
+		sb.WriteString("\n> " + searchLine(r, 0))
+		for _, c := range after {
+			sb.WriteString("\n  " + searchLine(c, searchContextLen))
+		}
+	}
+	return sb.String()
+}
+
+// searchLine 单行展示一条记录 max大于0时截断内容
+func searchLine(r *archive.Record, max int) string {
+	content := strings.Join(strings.Fields(r.Content), " ")
+	if runes := []rune(content); max > 0 && len(runes) > max {
+		content = string(runes[:max]) + "..."
+	}
+	line := fmt.Sprintf("%s %s(%s): %s", r.Time.Format("15:04:05"), r.Nickname, r.UserName, content)
+	if r.UserName == "" {
+		line = fmt.Sprintf("%s [%s] %s", r.Time.Format("15:04:05"), r.Type, content)
+	}
+	if r.Revoked {
+		line += " [已撤回]"
+	}
+	return line
+}
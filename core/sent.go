@@ -0,0 +1,144 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fishpi/command"
+)
+
+// maxSent 最多记住的自己发送的消息数量
+const maxSent = 20
+
+// ErrRevokeRefused 服务器拒绝撤回 一般是超过了撤回时间
+var ErrRevokeRefused = errors.New("服务器拒绝撤回 可能已经超过撤回时间")
+
+// sentHistory 自己最近发送的消息 从聊天室的回显中获取oId 撤回后删除
+type sentHistory struct {
+	mu   sync.Mutex
+	list []*WsMsgReply // 最新的在最后
+}
+
+func (s *sentHistory) add(msg *WsMsgReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = append(s.list, msg)
+	if len(s.list) > maxSent {
+		s.list = s.list[len(s.list)-maxSent:]
+	}
+}
+
+// remove 消息被撤回后调用
+func (s *sentHistory) remove(oId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range s.list {
+		if v.OId == oId {
+			s.list = append(s.list[:i:i], s.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// find target为空时返回最近一条 不超过历史数量的数字为倒数第几条 其余按oId查找
+// 不在历史中的oId也会返回 由服务器判断能否撤回
+func (s *sentHistory) find(target string) (*WsMsgReply, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if target == "" {
+		if len(s.list) == 0 {
+			return nil, errors.New("您最近还没有讲话")
+		}
+		return s.list[len(s.list)-1], nil
+	}
+	if n, err := strconv.Atoi(target); err == nil && n >= 1 && n <= len(s.list) {
+		return s.list[len(s.list)-n], nil
+	}
+	for _, v := range s.list {
+		if v.OId == target {
+			return v, nil
+		}
+	}
+	if _, err := strconv.ParseUint(target, 10, 64); err != nil {
+		return nil, fmt.Errorf("消息编号或者oId格式错误：%s", target)
+	}
+	return &WsMsgReply{Type: WsMsgTypeMsg, OId: target}, nil
+}
+
+// contains 是否为最近发送的消息
+func (s *sentHistory) contains(oId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.list {
+		if v.OId == oId {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sentHistory) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.list) == 0 {
+		return "您最近还没有讲话"
+	}
+	lines := make([]string, 0, len(s.list))
+	for i := len(s.list) - 1; i >= 0; i-- {
+		v := s.list[i]
+		lines = append(lines, fmt.Sprintf("%d. %s %s %s", len(s.list)-i, v.Time, v.OId, v.Md))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// revoke 撤回消息 服务器拒绝时返回 ErrRevokeRefused 并附带消息发送了多久
+func (s *sentHistory) revoke(sdk *Sdk, msg *WsMsgReply) error {
+	err := sdk.RevokeMsg(msg.OId)
+	var re *RevokeError
+	if errors.As(err, &re) {
+		age := ""
+		if t, e := time.ParseInLocation("2006-01-02 15:04:05", msg.Time, time.Local); e == nil {
+			age = fmt.Sprintf(" 消息发送于%s前", time.Since(t).Round(time.Second))
+		}
+		return fmt.Errorf("%w%s：%s", ErrRevokeRefused, age, re.Msg)
+	}
+	if err != nil {
+		return err
+	}
+	s.remove(msg.OId)
+	return nil
+}
+
+// sentCommands 撤回相关的指令 需要接收聊天室消息才能知道自己消息的oId
+func sentCommands(r *command.Registry, sdk *Sdk, sent *sentHistory) {
+	r.Register(
+		&command.Command{
+			Name: "sent",
+			Help: "查看自己最近发送的消息 编号用于revoke指令",
+			Run: func(c *command.Context) error {
+				c.Out.Print(sent.String())
+				return nil
+			},
+		},
+		&command.Command{
+			Name:  "revoke",
+			Usage: "[编号|oId]",
+			Help:  "撤回自己发送的消息 不填时撤回最近的一条 编号为sent列出的倒数第几条",
+			Run: func(c *command.Context) error {
+				msg, err := sent.find(c.Arg(0))
+				if err != nil {
+					return err
+				}
+				if err = sent.revoke(sdk, msg); err != nil {
+					return err
+				}
+				c.Out.Print("撤回消息操作成功")
+				return nil
+			},
+		},
+	)
+}
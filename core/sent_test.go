@@ -0,0 +1,67 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fishpi/logger"
+)
+
+func TestSentHistory(t *testing.T) {
+	var revoked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oId := strings.TrimPrefix(r.URL.Path, "/chat-room/revoke/")
+		if oId == "1000000000001" {
+			fmt.Fprint(w, `{"code":-1,"msg":"撤回时间已过"}`)
+			return
+		}
+		revoked = append(revoked, oId)
+		fmt.Fprint(w, `{"code":0}`)
+	}))
+	defer srv.Close()
+
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	api, _ := NewApi(srv.URL)
+	sdk := NewSdk(api, "test", "key", "me", l)
+
+	var s sentHistory
+	for _, oId := range []string{"1000000000001", "1000000000002", "1000000000003"} {
+		s.add(&WsMsgReply{Type: WsMsgTypeMsg, OId: oId, Time: "2024-05-01 12:00:00", UserName: "me", Md: "msg " + oId})
+	}
+
+	// 编号为倒数第几条
+	msg, err := s.find("2")
+	if err != nil || msg.OId != "1000000000002" {
+		t.Fatalf("find(2) = %v %v", msg, err)
+	}
+	if err = s.revoke(sdk, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ = s.find(""); msg.OId != "1000000000003" || s.contains("1000000000002") {
+		t.Errorf("revoked message still in history: %s", s.String())
+	}
+
+	msg, _ = s.find("1000000000001")
+	if err = s.revoke(sdk, msg); !errors.Is(err, ErrRevokeRefused) || !strings.Contains(err.Error(), "撤回时间已过") {
+		t.Errorf("revoke expired: %v", err)
+	}
+	if !s.contains("1000000000001") {
+		t.Error("撤回失败的消息不应当从历史中删除")
+	}
+
+	// 不在历史中的oId交给服务器判断
+	if msg, err = s.find("1000000000009"); err != nil || msg.OId != "1000000000009" {
+		t.Errorf("find unknown oId: %v %v", msg, err)
+	}
+	if _, err = s.find("abc"); err == nil {
+		t.Error("错误的oId应当报错")
+	}
+	if len(revoked) != 1 || revoked[0] != "1000000000002" {
+		t.Errorf("revoked %v", revoked)
+	}
+}
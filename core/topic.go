@@ -0,0 +1,13 @@
+package core
+
+import "fishpi/eventHandler"
+
+const (
+	RedPacket       = "red-packet"
+	RedPacketStatus = "red-packet-status"
+)
+
+var (
+	TopicRedPacket       = eventHandler.NewTopic[*WsMsgReply](RedPacket)       // 聊天室收到的红包消息
+	TopicRedPacketStatus = eventHandler.NewTopic[*WsMsgReply](RedPacketStatus) // 有人领取了红包
+)
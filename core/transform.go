@@ -0,0 +1,13 @@
+package core
+
+import "fishpi/transform"
+
+// transformMsg 展示前用插件改写聊天消息 结果保存在Display中 p为nil时不改写
+func transformMsg(p *transform.Pipeline, msg *WsMsgReply) {
+	if p == nil || msg.Type != WsMsgTypeMsg || msg.JsonInfo != nil {
+		return
+	}
+	m := &transform.Message{Text: msg.rawBody(), Html: msg.Content}
+	p.Apply(m)
+	msg.Display = &m.Text
+}
@@ -25,15 +25,15 @@ func NewElves(name, token string, logger logger.Logger) *Elves {
 		name:  name,
 		token: token,
 
-		logger: logger,
+		logger: logger.Named("elves"),
 	}
 
 	return e
 }
 
-func (e *Elves) HandleCall(data interface{}) {
+func (e *Elves) HandleCall(struct{}) {
 	if err := e.call(); err != nil {
-		e.logger.Logf("call stick error: %s", err)
+		e.logger.Error("call stick failed", "err", err)
 	}
 }
 
@@ -64,7 +64,7 @@ func (e *Elves) call() error {
 	if body, err = io.ReadAll(resp.Body); err != nil {
 		return err
 	}
-	e.logger.Logf("call stick result: %s", string(body))
+	e.logger.Info("call stick", "result", string(body))
 
 	return nil
 }
@@ -0,0 +1,163 @@
+package eventHandler
+
+import (
+	"strings"
+	"sync"
+
+	"fishpi/logger"
+)
+
+const wildcard = "*" // 以*结尾的事件名为前缀订阅 单独的*订阅全部事件
+
+type registry struct {
+	mu          sync.RWMutex
+	subscribers map[EventType][]*subscriber
+	patterns    []*subscriber
+	defaults    []SubOption
+}
+
+// Event 通配订阅收到的事件
+type Event struct {
+	Topic EventType   // 完整的事件名 包含命名空间
+	Data  interface{} // 事件数据
+}
+
+// Bus 事件总线 通过 Topic 发布和订阅带类型的事件
+type Bus struct {
+	name   string
+	prefix string // 命名空间前缀
+	reg    *registry
+
+	logger logger.Logger
+}
+
+// NewBus 创建总线 defaults为该总线上所有订阅的默认选项
+func NewBus(name string, logger logger.Logger, defaults ...SubOption) *Bus {
+	return &Bus{
+		name: name,
+		reg: &registry{
+			subscribers: make(map[EventType][]*subscriber),
+			defaults:    defaults,
+		},
+
+		logger: logger.Named("eventHandler").With("bus", name),
+	}
+}
+
+// Namespace 创建共享同一总线的子命名空间 子空间内的事件名为 {name}/{event}
+func (b *Bus) Namespace(name string) *Bus {
+	return &Bus{
+		name:   b.name + "/" + name,
+		prefix: b.prefix + name + "/",
+		reg:    b.reg,
+
+		logger: b.logger,
+	}
+}
+
+// SubscribePattern 按前缀订阅事件 例如 `chatroom/*` 或者 `*`
+func SubscribePattern(b *Bus, pattern string, method func(Event), opts ...SubOption) *Subscription {
+	return b.subscribe(EventType(pattern), true, func(data interface{}) {
+		method(data.(Event))
+	}, opts...)
+}
+
+func (b *Bus) publish(event EventType, data interface{}) {
+	topic := b.topic(event)
+
+	// 复制订阅者列表后再投递 投递过程中可以安全地订阅和取消订阅
+	b.reg.mu.RLock()
+	subscribers := append([]*subscriber(nil), b.reg.subscribers[topic]...)
+	var patterns []*subscriber
+	for _, s := range b.reg.patterns {
+		if s.match(topic) {
+			patterns = append(patterns, s)
+		}
+	}
+	b.reg.mu.RUnlock()
+
+	if len(subscribers) == 0 && len(patterns) == 0 {
+		b.logger.Debug("no methods for event", "event", topic)
+		return
+	}
+	for _, s := range subscribers {
+		s.push(data)
+	}
+	if len(patterns) != 0 {
+		ev := Event{Topic: topic, Data: data}
+		for _, s := range patterns {
+			s.push(ev)
+		}
+	}
+}
+
+func (b *Bus) subscribe(event EventType, pattern bool, method func(interface{}), opts ...SubOption) *Subscription {
+	options := subOptions{buffer: defaultBuffer, overflow: OverflowBlock}
+	for _, opt := range b.reg.defaults {
+		opt(&options)
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	topic := b.topic(event)
+	s := newSubscriber(topic, pattern, method, options, b.logger)
+
+	b.reg.mu.Lock()
+	if pattern {
+		b.reg.patterns = append(b.reg.patterns, s)
+	} else {
+		b.reg.subscribers[topic] = append(b.reg.subscribers[topic], s)
+	}
+	b.reg.mu.Unlock()
+
+	return &Subscription{reg: b.reg, s: s}
+}
+
+func (b *Bus) topic(event EventType) EventType {
+	return EventType(b.prefix) + event
+}
+
+func (r *registry) remove(s *subscriber) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s.pattern {
+		r.patterns = without(r.patterns, s)
+		return
+	}
+	if list := without(r.subscribers[s.event], s); len(list) == 0 {
+		delete(r.subscribers, s.event)
+	} else {
+		r.subscribers[s.event] = list
+	}
+}
+
+func without(list []*subscriber, s *subscriber) []*subscriber {
+	result := make([]*subscriber, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func isPattern(event EventType) bool {
+	return strings.HasSuffix(string(event), wildcard)
+}
+
+// Subscription 订阅句柄
+type Subscription struct {
+	reg  *registry
+	s    *subscriber
+	once sync.Once
+}
+
+// Unsubscribe 取消订阅 队列中尚未处理的事件会被丢弃 可以重复调用
+func (sub *Subscription) Unsubscribe() {
+	sub.once.Do(func() {
+		sub.reg.remove(sub.s)
+		sub.s.close()
+	})
+}
@@ -2,33 +2,27 @@ package eventHandler
 
 import "fishpi/logger"
 
-type eventHandler struct {
-	name    string
-	methods map[EventType][]func(interface{})
-
-	logger logger.Logger
+// NewEventHandler 创建总线并以字符串事件名的方式使用 新代码请使用 NewBus 和 Topic
+func NewEventHandler(name string, logger logger.Logger) *Bus {
+	return NewBus(name, logger)
 }
 
-func NewEventHandler(name string, logger logger.Logger) *eventHandler {
-	return &eventHandler{
-		name:    name,
-		methods: make(map[EventType][]func(interface{})),
-
-		logger: logger,
-	}
+// Pub 字符串事件名适配器
+func (b *Bus) Pub(event EventType, data interface{}) {
+	Publish(b, NewTopic[interface{}](event), data)
 }
 
-func (eh *eventHandler) Pub(event EventType, data interface{}) {
-	methods, ok := eh.methods[event]
-	if !ok {
-		eh.logger.Logf("EventHandler %s: no methods for event %s\n", eh.name, event)
-		return
-	}
-	for _, method := range methods {
-		go method(data)
+// Sub 字符串事件名适配器 以*结尾的事件名按前缀订阅
+func (b *Bus) Sub(event EventType, method func(interface{})) *Subscription {
+	if isPattern(event) {
+		return SubscribePattern(b, string(event), func(ev Event) {
+			method(ev.Data)
+		})
 	}
+	return Subscribe(b, NewTopic[interface{}](event), method)
 }
 
-func (eh *eventHandler) Sub(event EventType, method func(interface{})) {
-	eh.methods[event] = append(eh.methods[event], method)
+// SubOnce 字符串事件名适配器 只处理下一个事件
+func (b *Bus) SubOnce(event EventType, method func(interface{})) *Subscription {
+	return SubscribeOnce(b, NewTopic[interface{}](event), method)
 }
@@ -1,6 +1,8 @@
 package eventHandler
 
 import (
+	"log/slog"
+	"os"
 	"testing"
 	"time"
 
@@ -8,7 +10,7 @@ import (
 )
 
 func TestEventHandler(t *testing.T) {
-	l := logger.NewConsoleLogger()
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
 	eh := NewEventHandler("test", l)
 
 	var (
@@ -33,3 +35,252 @@ func TestEventHandler(t *testing.T) {
 
 	time.Sleep(time.Second)
 }
+
+func TestNamespace(t *testing.T) {
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
+	eh := NewEventHandler("test", l)
+
+	const eventLog EventType = "event_log"
+
+	got := make(chan string, 2)
+	eh.Namespace("a").Sub(eventLog, func(data interface{}) {
+		got <- "a:" + data.(string)
+	})
+	eh.Namespace("b").Sub(eventLog, func(data interface{}) {
+		got <- "b:" + data.(string)
+	})
+
+	eh.Namespace("a").Pub(eventLog, "hello")
+
+	select {
+	case v := <-got:
+		if v != "a:hello" {
+			t.Fatalf("unexpected receive: %s", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("namespace a did not receive event")
+	}
+	select {
+	case v := <-got:
+		t.Fatalf("event leaked to other namespace: %s", v)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTopic(t *testing.T) {
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
+	bus := NewBus("test", l)
+
+	got := make(chan ConnState, 1)
+	Subscribe(bus, TopicWsStatus, func(state ConnState) {
+		got <- state
+	})
+
+	// 通过字符串适配器发布错误类型的数据会被丢弃
+	bus.Pub(WsStatus, "Websocket Connect Success")
+	Publish(bus, TopicWsStatus, ConnState{Status: ConnClosed, Code: 1000})
+
+	select {
+	case state := <-got:
+		if state.Status != ConnClosed || state.Code != 1000 {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("typed subscriber did not receive event")
+	}
+}
+
+func TestOrderedDelivery(t *testing.T) {
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
+	bus := NewBus("test", l)
+	topic := NewTopic[int]("ordered")
+
+	const total = 1000
+	done := make(chan struct{})
+	next := 0
+	Subscribe(bus, topic, func(i int) {
+		if i != next {
+			t.Errorf("out of order: want %d, got %d", next, i)
+		}
+		next++
+		if next == total {
+			close(done)
+		}
+	}, WithBuffer(8))
+
+	for i := 0; i < total; i++ {
+		Publish(bus, topic, i)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("only %d of %d events delivered", next, total)
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
+	bus := NewBus("test", l)
+	topic := NewTopic[int]("overflow")
+
+	block := make(chan struct{})
+	got := make(chan int, 10)
+	Subscribe(bus, topic, func(i int) {
+		<-block
+		got <- i
+	}, WithBuffer(1), WithOverflow(OverflowDropNewest))
+
+	// 0正在处理 1进入队列 2被丢弃 发布者不会被阻塞
+	for i := 0; i < 3; i++ {
+		Publish(bus, topic, i)
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(block)
+
+	var received []int
+	timeout := time.After(time.Second)
+	for len(received) < 2 {
+		select {
+		case i := <-got:
+			received = append(received, i)
+		case <-timeout:
+			t.Fatalf("received %v", received)
+		}
+	}
+	select {
+	case i := <-got:
+		t.Fatalf("event %d should have been dropped", i)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if received[0] != 0 || received[1] != 1 {
+		t.Fatalf("unexpected events: %v", received)
+	}
+}
+
+func TestPanicIsolation(t *testing.T) {
+	l, m := logger.NewMemory(slog.LevelWarn)
+	bus := NewBus("test", l)
+	topic := NewTopic[string]("panic")
+
+	got := make(chan string, 2)
+	Subscribe(bus, topic, func(s string) {
+		if s == "boom" {
+			panic(s)
+		}
+		got <- s
+	})
+
+	Publish(bus, topic, "boom")
+	Publish(bus, topic, "after")
+
+	select {
+	case s := <-got:
+		if s != "after" {
+			t.Fatalf("unexpected event: %s", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber stopped after panic")
+	}
+	if r, ok := m.Find("panic"); !ok || r.Attrs["panic"] != "boom" || r.Attrs["component"] != "eventHandler" {
+		t.Fatalf("panic not logged: %+v", m.Records())
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
+	bus := NewBus("test", l)
+	topic := NewTopic[int]("unsubscribe")
+
+	got := make(chan int, 10)
+	sub := Subscribe(bus, topic, func(i int) {
+		got <- i
+	})
+	Publish(bus, topic, 1)
+	if i := <-got; i != 1 {
+		t.Fatalf("unexpected event: %d", i)
+	}
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	Publish(bus, topic, 2)
+	select {
+	case i := <-got:
+		t.Fatalf("received %d after unsubscribe", i)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSubscribeOnce(t *testing.T) {
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
+	bus := NewBus("test", l)
+	topic := NewTopic[int]("once")
+
+	got := make(chan int, 10)
+	SubscribeOnce(bus, topic, func(i int) {
+		got <- i
+	})
+	for i := 0; i < 5; i++ {
+		Publish(bus, topic, i)
+	}
+
+	if i := <-got; i != 0 {
+		t.Fatalf("unexpected event: %d", i)
+	}
+	select {
+	case i := <-got:
+		t.Fatalf("once subscriber received %d", i)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSubscribePattern(t *testing.T) {
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
+	bus := NewBus("test", l)
+	chatroom := bus.Namespace("chatroom")
+
+	got := make(chan Event, 10)
+	SubscribePattern(bus, "chatroom/*", func(ev Event) {
+		got <- ev
+	})
+
+	Publish(chatroom, TopicWsMsg, []byte("hi"))
+	Publish(bus.Namespace("ice"), TopicWsMsg, []byte("ignored"))
+
+	select {
+	case ev := <-got:
+		if ev.Topic != "chatroom/"+WsMsg || string(ev.Data.([]byte)) != "hi" {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pattern subscriber did not receive event")
+	}
+	select {
+	case ev := <-got:
+		t.Fatalf("pattern matched other namespace: %+v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestConcurrentSubscribe(t *testing.T) {
+	l := logger.NewLogger(os.Stderr, slog.LevelWarn)
+	bus := NewBus("test", l)
+	topic := NewTopic[int]("concurrent")
+
+	stop := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				Publish(bus, topic, i)
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		sub := Subscribe(bus, topic, func(int) {}, WithOverflow(OverflowDropNewest))
+		sub.Unsubscribe()
+	}
+	close(stop)
+}
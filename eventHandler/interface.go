@@ -1,18 +1,87 @@
 package eventHandler
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type EventType string
 
 const (
-	WsConnected       = "ws-connected"
-	WsClosed          = "ws-closed"
-	WsReconnectedFail = "ws-reconnected-fail"
-	WsMsg             = "ws-msg"
-	WsSend            = "ws-send"
+	WsStatus = "ws-status"
+	WsMsg    = "ws-msg"
+	WsSend   = "ws-send"
 
 	ElvesStick = `elves-stick` // 召唤小飞棍
 )
 
+var (
+	TopicWsStatus   = NewTopic[ConnState](WsStatus) // 连接状态变化
+	TopicWsMsg      = NewTopic[[]byte](WsMsg)       // 收到的websocket消息
+	TopicWsSend     = NewTopic[[]byte](WsSend)      // 需要发送的websocket消息
+	TopicElvesStick = NewTopic[struct{}](ElvesStick)
+)
+
+// EventHandler 字符串事件名的总线接口 仅作为 Bus 的适配器保留
 type EventHandler interface {
 	Pub(eventType EventType, data interface{})
-	Sub(eventType EventType, callback func(data interface{}))
+	Sub(eventType EventType, callback func(data interface{})) *Subscription
+	SubOnce(eventType EventType, callback func(data interface{})) *Subscription
+}
+
+type ConnStatus int
+
+const (
+	ConnConnected       ConnStatus = iota // 连接成功
+	ConnClosed                            // 连接关闭
+	ConnReconnectFailed                   // 重连失败
+)
+
+// ConnState 连接状态
+type ConnState struct {
+	Status ConnStatus
+	Addr   string
+	Code   int    // 关闭码
+	Text   string // 关闭原因
+	Err    error  // 重连失败原因
+}
+
+func (s ConnStatus) String() string {
+	switch s {
+	case ConnConnected:
+		return "connected"
+	case ConnClosed:
+		return "closed"
+	case ConnReconnectFailed:
+		return "reconnect-failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+func (c ConnState) MarshalJSON() ([]byte, error) {
+	var errMsg string
+	if c.Err != nil {
+		errMsg = c.Err.Error()
+	}
+	return json.Marshal(struct {
+		Status string `json:"status"`
+		Addr   string `json:"addr"`
+		Code   int    `json:"code,omitempty"`
+		Text   string `json:"text,omitempty"`
+		Err    string `json:"error,omitempty"`
+	}{c.Status.String(), c.Addr, c.Code, c.Text, errMsg})
+}
+
+func (c ConnState) String() string {
+	switch c.Status {
+	case ConnConnected:
+		return "Websocket Connect Success"
+	case ConnClosed:
+		return fmt.Sprintf("Websocket closed: \ncode: %d\ntext: %s\naddr: %s", c.Code, c.Text, c.Addr)
+	case ConnReconnectFailed:
+		return fmt.Sprintf("Websocket Reconnected failed\nerror: %s\naddr: %s", c.Err, c.Addr)
+	default:
+		return fmt.Sprintf("Websocket unknown status %d addr: %s", c.Status, c.Addr)
+	}
 }
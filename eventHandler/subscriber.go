@@ -0,0 +1,144 @@
+package eventHandler
+
+import (
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"fishpi/logger"
+)
+
+// Overflow 订阅者队列已满时的处理策略
+type Overflow int
+
+const (
+	OverflowBlock      Overflow = iota // 阻塞发布者直到队列有空位
+	OverflowDropOldest                 // 丢弃队列中最旧的事件
+	OverflowDropNewest                 // 丢弃当前发布的事件
+)
+
+const defaultBuffer = 1024
+
+type subOptions struct {
+	buffer   int
+	overflow Overflow
+}
+
+// SubOption 订阅选项
+type SubOption func(*subOptions)
+
+// WithBuffer 设置订阅者的队列长度
+func WithBuffer(n int) SubOption {
+	return func(o *subOptions) {
+		if n > 0 {
+			o.buffer = n
+		}
+	}
+}
+
+// WithOverflow 设置队列已满时的处理策略
+func WithOverflow(overflow Overflow) SubOption {
+	return func(o *subOptions) {
+		o.overflow = overflow
+	}
+}
+
+// subscriber 每个订阅者拥有独立的队列和处理协程 保证事件按发布顺序处理
+type subscriber struct {
+	event    EventType
+	pattern  bool
+	method   func(interface{})
+	queue    chan interface{}
+	overflow Overflow
+	done     chan struct{}
+	once     sync.Once
+
+	logger logger.Logger
+}
+
+func newSubscriber(event EventType, pattern bool, method func(interface{}), options subOptions, logger logger.Logger) *subscriber {
+	s := &subscriber{
+		event:    event,
+		pattern:  pattern,
+		method:   method,
+		queue:    make(chan interface{}, options.buffer),
+		overflow: options.overflow,
+		done:     make(chan struct{}),
+		logger:   logger,
+	}
+
+	go s.run()
+	return s
+}
+
+func (s *subscriber) match(topic EventType) bool {
+	return strings.HasPrefix(string(topic), strings.TrimSuffix(string(s.event), wildcard))
+}
+
+func (s *subscriber) push(data interface{}) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	switch s.overflow {
+	case OverflowDropNewest:
+		select {
+		case s.queue <- data:
+		case <-s.done:
+		default:
+			s.logger.Warn("subscriber queue is full, drop newest event", "event", s.event)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.queue <- data:
+				return
+			default:
+			}
+			select {
+			case <-s.queue:
+				s.logger.Warn("subscriber queue is full, drop oldest event", "event", s.event)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.queue <- data:
+		case <-s.done:
+		}
+	}
+}
+
+func (s *subscriber) run() {
+	for {
+		select {
+		case data := <-s.queue:
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.call(data)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *subscriber) close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+// call 隔离订阅者的panic 避免一个订阅者导致整个程序崩溃
+func (s *subscriber) call(data interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("subscriber panic", "event", s.event, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	s.method(data)
+}
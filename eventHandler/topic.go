@@ -0,0 +1,56 @@
+package eventHandler
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Topic 带类型的事件主题 同一主题的发布者和订阅者的数据类型在编译期保持一致
+type Topic[T any] struct {
+	name EventType
+}
+
+func NewTopic[T any](name EventType) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+func (t Topic[T]) Name() EventType {
+	return t.name
+}
+
+// Publish 向总线发布事件
+func Publish[T any](b *Bus, topic Topic[T], data T) {
+	b.publish(topic.name, data)
+}
+
+// Subscribe 订阅事件 只有通过字符串适配器发布了错误类型的数据时才会被丢弃
+// 同一订阅者按发布顺序串行处理事件 队列长度和溢出策略可以通过opts设置
+func Subscribe[T any](b *Bus, topic Topic[T], method func(T), opts ...SubOption) *Subscription {
+	return b.subscribe(topic.name, false, func(data interface{}) {
+		v, ok := data.(T)
+		if !ok {
+			b.logger.Error("event payload type mismatch", "event", topic.name, "type", fmt.Sprintf("%T", data))
+			return
+		}
+		method(v)
+	}, opts...)
+}
+
+// SubscribeOnce 只处理下一个事件 处理后自动取消订阅
+func SubscribeOnce[T any](b *Bus, topic Topic[T], method func(T)) *Subscription {
+	var (
+		sub   *Subscription
+		fired atomic.Bool
+		ready = make(chan struct{})
+	)
+	sub = Subscribe(b, topic, func(v T) {
+		if !fired.CompareAndSwap(false, true) {
+			return
+		}
+		<-ready
+		sub.Unsubscribe()
+		method(v)
+	})
+	close(ready)
+	return sub
+}
@@ -1,13 +1,11 @@
 package ice
 
 import (
-	"bufio"
 	"encoding/json"
-	"log"
-	"os"
 	"strings"
 	"time"
 
+	"fishpi/eventHandler"
 	"fishpi/logger"
 )
 
@@ -19,10 +17,11 @@ type core struct {
 	ch       chan []byte
 	updateCk func(ck string) error
 
-	logger logger.Logger
+	display logger.Display
+	logger  logger.Logger
 }
 
-func NewCore(ck, username, uid string, logger logger.Logger) *core {
+func NewCore(ck, username, uid string, display logger.Display, logger logger.Logger) *core {
 	c := &core{
 		ck:       ck,
 		username: username,
@@ -30,7 +29,8 @@ func NewCore(ck, username, uid string, logger logger.Logger) *core {
 
 		ch: make(chan []byte, 1000),
 
-		logger: logger,
+		display: display,
+		logger:  logger.Named("ice"),
 	}
 
 	return c
@@ -47,16 +47,10 @@ func (c *core) login() {
 	c.ch <- body
 }
 
-func (c *core) HandleMsg(data interface{}) {
-	bytes, ok := data.([]byte)
-	if !ok {
-		c.logger.Logf("data is not []byte: %v", string(bytes))
-		return
-	}
-
+func (c *core) HandleMsg(bytes []byte) {
 	msg := &ExchangeMsg{}
 	if err := json.Unmarshal(bytes, &msg); err != nil {
-		log.Printf("parse message error: %s, body: %s\n", err, string(bytes))
+		c.logger.Warn("parse message failed", "err", err, "body", string(bytes))
 		return
 	}
 
@@ -68,22 +62,23 @@ func (c *core) HandleMsg(data interface{}) {
 	m = strings.ReplaceAll(m, "</details>", "")
 
 	if msg.Type == TypeAll {
-		c.logger.Log(m)
+		c.display.Print(m)
 		c.login()
 	} else if msg.Type == TypeSetCK {
 		c.ck = msg.Ck
-		c.logger.Logf("your new ck is: %s", msg.Ck)
+		c.display.Printf("your new ck is: %s", msg.Ck)
 		if err := c.updateCk(msg.Ck); err != nil {
-			c.logger.Logf("update ck config file error, please manual update, %s", msg.Ck)
+			c.logger.Error("update ck config file failed", "err", err)
+			c.display.Printf("update ck config file error, please manual update, %s", msg.Ck)
 		}
 	} else if msg.Type == TypeGameMsg {
 		if msg.VipLv != 0 {
-			c.logger.Logf("%s %s", msg.Level(), m)
+			c.display.Printf("%s %s", msg.Level(), m)
 		} else {
-			c.logger.Log(m)
+			c.display.Print(m)
 		}
 	} else {
-		c.logger.Log(string(bytes))
+		c.display.Print(string(bytes))
 	}
 }
 
@@ -91,35 +86,21 @@ func (c *core) SetUpdateCKFunc(f func(ck string) error) {
 	c.updateCk = f
 }
 
-func (c *core) HandleWsStatusMsg(data interface{}) {
-	str, ok := data.(string)
-	if !ok {
-		c.logger.Logf("data is not string: %v", str)
-		return
-	}
-	c.logger.Log(str)
+func (c *core) HandleWsStatusMsg(state eventHandler.ConnState) {
+	c.display.Print(state.String())
 }
 
-func (c *core) Watch() {
-	for {
-		var buf [1024]byte
-		read := bufio.NewReader(os.Stdin)
-		m, err := read.Read(buf[:])
-		if err != nil {
-			log.Printf("read error: %s\n", err)
-			continue
-		}
-		recv := strings.Split(string(buf[:m]), "\n")[0]
-		recv = strings.TrimSpace(recv)
-		if recv == "" {
-			continue
-		}
-		if strings.HasPrefix(recv, "登录 ") {
-			go c.handleLogin(recv)
-			continue
-		}
-		go c.handleCommand(recv)
+// HandleInput 处理终端输入的游戏指令
+func (c *core) HandleInput(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
 	}
+	if strings.HasPrefix(line, "登录 ") {
+		c.handleLogin(line)
+		return
+	}
+	c.handleCommand(line)
 }
 
 func (c *core) handleLogin(cmd string) {
@@ -140,10 +121,9 @@ func (c *core) handleCommand(cmd string) {
 }
 
 func (c *core) KeepLive() <-chan []byte {
-	ticker := time.NewTicker(3 * time.Minute)
-	defer ticker.Stop()
-
 	go func() {
+		ticker := time.NewTicker(3 * time.Minute)
+		defer ticker.Stop()
 		for {
 			select {
 			case _ = <-ticker.C:
@@ -4,27 +4,28 @@ import (
 	"fmt"
 )
 
-type consoleLogger struct {
+type console struct {
 	name string
 }
 
-func NewConsoleLogger() *consoleLogger {
+// NewConsole 输出到终端的Display
+func NewConsole() *console {
 
-	return &consoleLogger{}
+	return &console{}
 }
 
-func (c *consoleLogger) SetName(name string) {
+func (c *console) SetName(name string) {
 	c.name = name
 }
 
-func (c *consoleLogger) Log(msg string) {
+func (c *console) Print(msg string) {
 	if c.name != "" {
 		msg = fmt.Sprintf("[%s] %s", c.name, msg)
 	}
 	fmt.Println(msg)
 }
 
-func (c *consoleLogger) Logf(format string, a ...interface{}) {
+func (c *console) Printf(format string, a ...interface{}) {
 	msg := fmt.Sprintf(format, a...)
-	c.Log(msg)
+	c.Print(msg)
 }
@@ -1,6 +1,20 @@
 package logger
 
+// Logger 诊断日志 分级并且支持结构化字段 args为键值对 例如 Error("读取消息失败", "addr", addr, "err", err)
 type Logger interface {
-	Logf(format string, a ...interface{})
-	Log(msg string)
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+
+	// With 返回附带固定字段的Logger
+	With(args ...any) Logger
+	// Named 返回附带组件名的Logger 例如 ws core ice
+	Named(component string) Logger
+}
+
+// Display 面向用户的输出 例如聊天内容和指令结果 与诊断日志分开
+type Display interface {
+	Printf(format string, a ...interface{})
+	Print(msg string)
 }
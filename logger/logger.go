@@ -1,35 +1,52 @@
 package logger
 
 import (
-	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
 	"log/slog"
+	"os"
+
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func init() {
-	New()
-}
+// Options 诊断日志配置
+type Options struct {
+	Level  slog.Leveler // 默认info 可以是 *slog.LevelVar 以便运行中修改
+	Writer io.Writer    // File为空时的输出位置 默认标准错误
 
-func New() *slog.Logger {
+	File       string // 日志文件 按大小切割
+	MaxSize    int    // 每个日志文件的最大尺寸，单位为 MB
+	MaxBackups int    // 保留的旧日志文件的最大数量
+	MaxAge     int    // 保留的旧日志文件的最大天数
+	Compress   bool   // 是否压缩旧日志文件
+}
 
-	// 创建 lumberjack.Logger 对象，配置日志文件路径和其他属性
-	lumberjackLogger := &lumberjack.Logger{
-		Filename:   "_tmp/log.log",
-		MaxSize:    5,    // 每个日志文件的最大尺寸，单位为 MB
-		MaxBackups: 3,    // 保留的旧日志文件的最大数量
-		MaxAge:     30,   // 保留的旧日志文件的最大天数
-		Compress:   true, // 是否压缩旧日志文件
+// New 根据配置创建诊断日志 并设置为slog的默认Logger 返回的io.Closer用于关闭日志文件
+func New(o Options) (Logger, io.Closer) {
+	var w io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if o.File != "" {
+		lj := &lumberjack.Logger{
+			Filename:   o.File,
+			MaxSize:    o.MaxSize,
+			MaxBackups: o.MaxBackups,
+			MaxAge:     o.MaxAge,
+			Compress:   o.Compress,
+		}
+		w, closer = lj, lj
+	} else if o.Writer != nil {
+		w = o.Writer
+	}
+	if o.Level == nil {
+		o.Level = slog.LevelInfo
 	}
 
-	logger := slog.New(slog.NewTextHandler(io.MultiWriter(lumberjackLogger), &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}))
-
-	slog.SetDefault(logger)
-
-	return logger
+	l := NewLogger(w, o.Level)
+	SetDefault(l)
+	return l, closer
 }
 
-func RecordMessage(message interface{}) {
-	slog.Debug("message", slog.Any("message", message))
+type nopCloser struct{}
+
+func (nopCloser) Close() error {
+	return nil
 }
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Record 内存中的一条日志
+type Record struct {
+	Level slog.Level
+	Msg   string
+	Attrs map[string]any
+}
+
+// Memory 保存在内存中的日志 用于测试
+type Memory struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewMemory 创建输出到内存的Logger
+func NewMemory(level slog.Leveler) (Logger, *Memory) {
+	m := new(Memory)
+	return &slogLogger{l: slog.New(&memoryHandler{m: m, level: level})}, m
+}
+
+// Records 已记录的日志
+func (m *Memory) Records() []Record {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Record(nil), m.records...)
+}
+
+// Find 返回第一条消息包含msg的日志
+func (m *Memory) Find(msg string) (Record, bool) {
+	for _, r := range m.Records() {
+		if strings.Contains(r.Msg, msg) {
+			return r, true
+		}
+	}
+	return Record{}, false
+}
+
+func (m *Memory) add(r Record) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, r)
+}
+
+// memoryHandler 忽略分组 所有字段平铺在Attrs中
+type memoryHandler struct {
+	m     *Memory
+	level slog.Leveler
+	attrs []slog.Attr
+}
+
+func (h *memoryHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.level == nil || level >= h.level.Level()
+}
+
+func (h *memoryHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make(map[string]any, len(h.attrs)+r.NumAttrs())
+	for _, a := range h.attrs {
+		attrs[a.Key] = a.Value.Any()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.Any()
+		return true
+	})
+	h.m.add(Record{Level: r.Level, Msg: r.Message, Attrs: attrs})
+	return nil
+}
+
+func (h *memoryHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &memoryHandler{m: h.m, level: h.level, attrs: append(append([]slog.Attr(nil), h.attrs...), attrs...)}
+}
+
+func (h *memoryHandler) WithGroup(string) slog.Handler {
+	return h
+}
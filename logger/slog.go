@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const componentKey = "component"
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewLogger 以文本格式输出到w level可以是 *slog.LevelVar 以便运行中修改
+func NewLogger(w io.Writer, level slog.Leveler) Logger {
+	return &slogLogger{l: slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))}
+}
+
+// ParseLevel 解析日志等级 debug info warn error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(strings.TrimSpace(s)))
+	return level, err
+}
+
+// SetDefault 设置slog的默认Logger 没有注入Logger的地方直接使用slog
+func SetDefault(l Logger) {
+	if s, ok := l.(*slogLogger); ok {
+		slog.SetDefault(s.l)
+	}
+}
+
+func (s *slogLogger) Debug(msg string, args ...any) {
+	s.l.Debug(msg, args...)
+}
+
+func (s *slogLogger) Info(msg string, args ...any) {
+	s.l.Info(msg, args...)
+}
+
+func (s *slogLogger) Warn(msg string, args ...any) {
+	s.l.Warn(msg, args...)
+}
+
+func (s *slogLogger) Error(msg string, args ...any) {
+	s.l.Error(msg, args...)
+}
+
+func (s *slogLogger) With(args ...any) Logger {
+	return &slogLogger{l: s.l.With(args...)}
+}
+
+func (s *slogLogger) Named(component string) Logger {
+	return s.With(componentKey, component)
+}
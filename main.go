@@ -1,181 +1,489 @@
 package main
 
 import (
-	"fishpi/simple"
+	"context"
 	"flag"
-
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"fishpi/archive"
+	"fishpi/block"
+	"fishpi/bridge"
 	"fishpi/config"
 	"fishpi/core"
 	"fishpi/elves"
 	"fishpi/eventHandler"
 	"fishpi/ice"
 	"fishpi/logger"
-	"fishpi/ws"
+	"fishpi/notify"
+	"fishpi/redpacket"
+	"fishpi/session"
+	"fishpi/setup"
+	"fishpi/simple"
+	"fishpi/transform"
 )
 
 // 💦
 var (
 	confPath   = flag.String("conf", "./_tmp/config.yaml", "config path, default: ./_tmp/config.yml")
+	initConf   = flag.Bool("init", false, "首次使用 登录并生成配置文件(false)")
 	login      = flag.Bool("login", false, "是否登录操作(false)")
 	wsMode     = flag.Bool("ws", false, "是否接收消息模式(false)")
 	message    = flag.Bool("msg", false, "是否发送消息模式(false)")
+	replMode   = flag.Bool("repl", false, "是否使用读写合一的聊天室模式 普通输入作为消息发送 以/开头的输入为指令(false)")
 	iceMode    = flag.Bool("ice", false, "是否开启小冰游戏模式(false)")
 	simpleMode = flag.Bool("simple", false, "是否使用simple UI模式(false)")
+	chatUser   = flag.String("chat", "", "私聊对象的用户名 为空则不开启私聊")
+	notice     = flag.Bool("notice", false, "是否接收用户通知(false)")
+	checkConf  = flag.Bool("check-config", false, "校验配置文件后退出(false)")
+	printConf  = flag.Bool("print-config", false, "打印生效的配置后退出 敏感字段会被隐藏(false)")
+	migrate    = flag.Bool("migrate-secrets", false, "把配置文件中的明文敏感字段迁移到加密的密钥文件后退出(false)")
+	overrides  = config.RegisterFlags(flag.CommandLine)
 )
 
 func main() {
 	// 解析配置信息
 	flag.Parse()
 
-	// 初始化日志程序
-	loger := logger.NewConsoleLogger()
+	// 终端输出 聊天内容和指令结果
+	display := logger.NewConsole()
+
+	// 配置向导
+	if *initConf {
+		if err := setup.NewWizard(*confPath, logger.NewLogger(os.Stderr, slog.LevelWarn)).Run(); err != nil {
+			display.Printf("初始化失败 %s", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// 读取配置文件
-	conf, err := config.NewConfig(*confPath)
+	conf, err := config.Load(*confPath, overrides)
+	if *checkConf {
+		if err != nil {
+			display.Printf("配置文件路径：%s\n%s", *confPath, err)
+			os.Exit(1)
+		}
+		display.Printf("配置文件校验通过：%s", *confPath)
+		return
+	}
 	if err != nil {
-		loger.Logf("读取配置文件失败 \n配置文件路径：%s\n错误信息：%s", *confPath, err)
+		display.Printf("读取配置文件失败 \n配置文件路径：%s\n错误信息：%s", *confPath, err)
+		return
+	}
+	if *printConf {
+		display.Print(conf.Masked())
 		return
 	}
+	if *migrate {
+		n, err := conf.MigrateSecrets()
+		if err != nil {
+			display.Printf("迁移敏感字段失败 %s", err)
+			os.Exit(1)
+		}
+		display.Printf("已迁移%d个敏感字段到密钥文件：%s", n, conf.Secrets.File)
+		return
+	}
+
+	// 初始化诊断日志
+	logLevel := new(slog.LevelVar)
+	loger, closer := newLogger(conf.Log, logLevel, *simpleMode)
+	defer closer.Close()
 
 	// 初始化FishPi API
 	var api *core.Api
 	if api, err = core.NewApi(conf.FishPi.ApiBase); err != nil {
-		loger.Logf("FishPi地址信息填写失败 %s", err)
+		display.Printf("FishPi地址信息填写失败 %s", err)
 		return
 	}
 
-	fishPiSdk := core.NewSdk(api, conf.FishPi.ApiBase, conf.FishPi.ApiKey, conf.FishPi.Username, loger)
+	fishPiSdk := core.NewSdk(api, conf.FishPi.ApiBase, conf.FishPi.ApiKey, conf.FishPi.Username, loger).
+		SetLedger(redpacket.NewLedger(conf.LedgerPath(), loger))
 
 	// 登录操作
 	if *login {
 		if err = fishPiSdk.GetKey(conf.FishPi.Username, conf.FishPi.PasswordMd5, conf.FishPi.MfaCode); err != nil {
-			loger.Logf("登陆失败 %s", err)
+			display.Printf("登陆失败 %s", err)
 			return
 		}
 
 		key := fishPiSdk.GetApiKey()
 		if err = conf.UpdateApiKey(key); err != nil {
-			loger.Logf("更新配置文件的ApiKey错误，请手动更新\n新的ApiKey：%s\n错误信息：%s", key, err)
+			display.Printf("更新配置文件的ApiKey错误，请手动更新\n新的ApiKey：%s\n错误信息：%s", key, err)
 			return
 		}
-		loger.Log("更新成功！")
+		display.Print("更新成功！")
 
 		return
 	}
 
-	// 接收消息模式
-	if *wsMode {
-
-		// 初始化消息处理器
-		hl := core.NewHandler(conf.Settings.MsgCacheNum, conf.Elves.Token, fishPiSdk, loger)
+	// 之后终端由会话读取 密钥文件口令只能通过环境变量提供
+	conf.DisablePrompt()
+	sess := session.NewSession(display, loger)
+	bus := eventHandler.NewBus("session", loger)
 
-		// 初始化事件触发器
-		eh := eventHandler.NewEventHandler("websocket", loger)
-		eh.Sub(eventHandler.WsMsg, hl.HandleMsg)
-		eh.Sub(eventHandler.WsConnected, hl.HandleWsStatusMsg)
-		eh.Sub(eventHandler.WsClosed, hl.HandleWsStatusMsg)
-		eh.Sub(eventHandler.WsReconnectedFail, hl.HandleWsStatusMsg)
-
-		// 连接ws
-		u, e := fishPiSdk.GetWsUrl()
-		if e != nil {
-			loger.Logf("获取聊天室节点失败 %s", err)
+	// 监听配置文件 过滤规则等配置修改后直接生效
+	sess.Add(config.NewWatcher(conf, overrides, 2*time.Second, bus, loger))
+	filter := core.NewFilter(conf.Filter.BlockUsers, conf.Filter.Keywords)
+	eventHandler.Subscribe(bus, config.TopicReload, func(r *config.Reload) {
+		if r.Err != nil {
 			return
 		}
-		wsClient := ws.NewWs(u, conf.Settings.WsInterval, eh, loger)
+		filter.Update(r.New.Filter.BlockUsers, r.New.Filter.Keywords)
+		if level, err := logger.ParseLevel(r.New.Log.Level); err == nil {
+			logLevel.Set(level)
+		}
+	})
 
-		if err = wsClient.Start(); err != nil {
-			loger.Logf("websocket连接失败 %s", err)
-			return
+	// 对外推送事件
+	var br *bridge.Bridge
+	if conf.Bridge != nil && conf.Bridge.Addr != "" {
+		br = bridge.NewBridge(conf.Bridge.Addr, conf.Bridge.Token, bus, fishPiSdk, loger)
+		sess.Add(br)
+	}
+
+	// 聊天记录存档 只有接收聊天室消息时打开 存档文件同时只能被一个进程使用 打开失败时不影响其他功能
+	var arc *archive.Archive
+	if *simpleMode || *wsMode || *replMode {
+		if arc = openArchive(conf, loger); arc != nil {
+			sess.Add(arc)
 		}
-		loger.Logf("已连接到节点 %s", u)
-		c := hl.KeepLive()
-		go hl.Watch()
-		for {
-			select {
-			case ping := <-c:
-				wsClient.Send([]byte(ping))
-			}
+	}
+
+	// 屏蔽规则 block指令添加 其他进程修改后自动重新加载
+	blocks, err := block.Open(conf.BlockPath(), loger)
+	if err != nil {
+		loger.Warn("屏蔽规则不可用", "err", err)
+	} else {
+		sess.Add(blocks)
+	}
+
+	// @自己、关键词和关注用户的提醒 只有接收聊天室消息时开启
+	var nt *notify.Notify
+	if *simpleMode || *wsMode || *replMode {
+		if nt, err = notify.New(notifyOptions(conf), display, loger); err != nil {
+			loger.Warn("提醒不可用", "err", err)
+		} else {
+			nt.SetBlocks(blocks)
+			eventHandler.Subscribe(bus, config.TopicReload, func(r *config.Reload) {
+				if r.Err != nil {
+					return
+				}
+				if err := nt.Update(notifyOptions(r.New)); err != nil {
+					loger.Warn("提醒配置更新失败 继续使用之前的配置", "err", err)
+				}
+			})
 		}
 	}
 
-	// 小冰游戏
-	if *iceMode {
+	// 自动抢红包 只有接收聊天室消息时开启
+	var auto *core.AutoOpen
+	if *simpleMode || *wsMode || *replMode {
+		auto = core.NewAutoOpen(fishPiSdk, display, loger)
+		if err = auto.Update(conf.RedPacket.Auto, conf.RedPacket.Rules); err != nil {
+			loger.Warn("自动抢红包规则错误", "err", err)
+		}
+		eventHandler.Subscribe(bus, config.TopicReload, func(r *config.Reload) {
+			if r.Err != nil {
+				return
+			}
+			if err := auto.Update(r.New.RedPacket.Auto, r.New.RedPacket.Rules); err != nil {
+				loger.Warn("自动抢红包配置更新失败 继续使用之前的配置", "err", err)
+			}
+		})
+	}
 
-		// 初始化消息处理器
-		hl := ice.NewCore(conf.Ice.Ck, conf.Ice.Username, conf.Ice.Uid, loger)
-		hl.SetUpdateCKFunc(conf.UpdateCK)
+	// 消息内容改写插件 所有接收聊天室消息的模式共用
+	pipeline, err := transform.New(transformOptions(conf))
+	if err != nil {
+		loger.Warn("消息改写插件配置错误 使用默认配置", "err", err)
+		pipeline, _ = transform.New(transform.Options{Token: conf.Elves.Token})
+	}
+	eventHandler.Subscribe(bus, config.TopicReload, func(r *config.Reload) {
+		if r.Err != nil {
+			return
+		}
+		if err := pipeline.Update(transformOptions(r.New)); err != nil {
+			loger.Warn("消息改写插件配置更新失败 继续使用之前的配置", "err", err)
+		}
+	})
+
+	// 召唤小飞棍 stick指令在所有模式中可用
+	ec := elves.NewElves(conf.FishPi.Username, conf.Elves.Token, loger)
+
+	// 简单UI模式 独占终端
+	if *simpleMode {
+		if sessionMode() {
+			display.Print("simple模式独占终端 已忽略其他模式")
+		}
 
 		// 初始化事件触发器
-		eh := eventHandler.NewEventHandler("websocket", loger)
-		eh.Sub(eventHandler.WsMsg, hl.HandleMsg)
-		eh.Sub(eventHandler.WsConnected, hl.HandleWsStatusMsg)
-		eh.Sub(eventHandler.WsClosed, hl.HandleWsStatusMsg)
-		eh.Sub(eventHandler.WsReconnectedFail, hl.HandleWsStatusMsg)
+		eh := bus.Namespace("chatroom")
 
-		// 连接ws
-		wsClient := ws.NewWs(conf.Ice.Url, conf.Settings.WsInterval, eh, loger)
+		// 初始化公共聊天室核心逻辑
+		hl := core.NewCore(conf.Settings.MsgCacheNum, fishPiSdk, eh).SetPipeline(pipeline).SetFilter(filter).SetArchive(arc).SetBlocks(blocks)
+		if br != nil {
+			br.SetPacketType(hl.PacketType)
+		}
 
-		if err = wsClient.Start(); err != nil {
-			loger.Logf("websocket连接失败 %s", err)
-			return
+		eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, hl.HandleMsg)
+		eventHandler.Subscribe(eh, eventHandler.TopicWsStatus, hl.HandleWsStatusMsg)
+		eventHandler.Subscribe(eh, eventHandler.TopicElvesStick, ec.HandleCall)
+		if arc != nil {
+			eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, arc.HandleMsg)
 		}
-		c := hl.KeepLive()
-		go hl.Watch()
-		for {
-			select {
-			case msg := <-c:
-				wsClient.Send(msg)
-			}
+		if nt != nil {
+			eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, nt.HandleMsg)
+		}
+		eventHandler.Subscribe(eh, core.TopicRedPacket, auto.HandleRedPacket)
+		eventHandler.Subscribe(eh, core.TopicRedPacketStatus, auto.HandleStatus)
+
+		ui := simple.NewSimple(hl)
+		if nt != nil {
+			nt.SetDisplay(ui, false)
+		}
+		auto.SetDisplay(ui)
+		ws := session.NewWsService("chatroom", fishPiSdk.GetWsUrl, conf.Settings.WsInterval, eh, loger)
+		onReload(bus, ws, hl.SetCacheNum)
+		sess.Add(ws)
+		sess.Add(session.NewService("simple", func(ctx context.Context) error {
+			go func() {
+				<-ctx.Done()
+				ui.Stop()
+			}()
+			defer sess.Stop()
+			return ui.Start()
+		}, nil))
+		sess.DisableStdin()
+
+		run(sess, loger)
+		return
+	}
+
+	// 读写合一的聊天室 包含接收和发送 不再单独开启
+	if *replMode {
+		if *wsMode || *message {
+			display.Print("repl模式已经包含接收和发送 已忽略-ws和-msg")
+			*wsMode, *message = false, false
+		}
+
+		eh := bus.Namespace("chatroom")
+
+		hl := core.NewHandler(conf.Settings.MsgCacheNum, fishPiSdk, eh, display, loger).SetPipeline(pipeline).SetFilter(filter).SetArchive(arc).SetBlocks(blocks)
+		if br != nil {
+			br.SetPacketType(hl.PacketType)
+		}
+		client := core.NewClient(fishPiSdk, eh, display, loger)
+		repl := core.NewRepl(hl, client, display)
+
+		eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, hl.HandleMsg)
+		eventHandler.Subscribe(eh, eventHandler.TopicWsStatus, hl.HandleWsStatusMsg)
+		eventHandler.Subscribe(eh, eventHandler.TopicElvesStick, ec.HandleCall)
+		if arc != nil {
+			eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, arc.HandleMsg)
 		}
+		if nt != nil {
+			eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, nt.HandleMsg)
+		}
+		eventHandler.Subscribe(eh, core.TopicRedPacket, auto.HandleRedPacket)
+		eventHandler.Subscribe(eh, core.TopicRedPacketStatus, auto.HandleStatus)
+
+		// 输入交给repl 聊天室连接只负责接收
+		sess.Add(session.NewService("repl", func(ctx context.Context) error {
+			repl.Start()
+			<-ctx.Done()
+			return nil
+		}, repl.HandleInput))
+		ws := session.NewWsService("chatroom", fishPiSdk.GetWsUrl, conf.Settings.WsInterval, eh, loger).
+			SetOutbound(hl.KeepLive())
+		onReload(bus, ws, hl.SetCacheNum)
+		sess.Add(ws)
 	}
 
 	// 发送消息模式
 	if *message {
+		eh := bus.Namespace("msg")
 
-		ec := elves.NewElves(conf.FishPi.Username, conf.Elves.Token, loger)
-
-		eh := eventHandler.NewEventHandler("default", loger)
-		eh.Sub(eventHandler.ElvesStick, ec.HandleCall)
+		eventHandler.Subscribe(eh, eventHandler.TopicElvesStick, ec.HandleCall)
 
-		client := core.NewClient(fishPiSdk, eh, loger)
-		client.SendMode()
+		client := core.NewClient(fishPiSdk, eh, display, loger).SetArchive(arc).SetBlocks(blocks)
+		sess.Add(session.NewService("msg", func(ctx context.Context) error {
+			client.Start()
+			<-ctx.Done()
+			return nil
+		}, client.HandleInput))
 	}
 
-	// 简单UI模式
-	if *simpleMode {
-		// 初始化事件触发器
-		eh := eventHandler.NewEventHandler("public-websocket", loger)
+	// 接收消息模式
+	if *wsMode {
+		eh := bus.Namespace("chatroom")
 
-		// 初始化公共聊天室核心逻辑
-		hl := core.NewCore(conf.Settings.MsgCacheNum, conf.Elves.Token, fishPiSdk, eh)
-
-		eh.Sub(eventHandler.WsMsg, hl.HandleMsg)
-		//eh.Sub(eventHandler.WsMsg, logger.RecordMessage)
-		eh.Sub(eventHandler.WsConnected, hl.HandleWsStatusMsg)
-		eh.Sub(eventHandler.WsClosed, hl.HandleWsStatusMsg)
-		eh.Sub(eventHandler.WsReconnectedFail, hl.HandleWsStatusMsg)
-
-		// 连接ws
-		u, e := fishPiSdk.GetWsUrl()
-		if e != nil {
-			loger.Logf("获取聊天室节点失败 %s", err)
-			return
+		// 初始化消息处理器
+		hl := core.NewHandler(conf.Settings.MsgCacheNum, fishPiSdk, eh, display, loger).SetPipeline(pipeline).SetFilter(filter).SetArchive(arc).SetBlocks(blocks)
+		if br != nil {
+			br.SetPacketType(hl.PacketType)
 		}
-		wsClient := ws.NewWs(u, conf.Settings.WsInterval, eh, loger)
-		eh.Sub(eventHandler.WsSend, wsClient.Send)
 
-		if err = wsClient.Start(); err != nil {
-			loger.Logf("websocket连接失败 %s", err)
-			return
+		eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, hl.HandleMsg)
+		eventHandler.Subscribe(eh, eventHandler.TopicWsStatus, hl.HandleWsStatusMsg)
+		eventHandler.Subscribe(eh, eventHandler.TopicElvesStick, ec.HandleCall)
+		if arc != nil {
+			eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, arc.HandleMsg)
 		}
-
-		ui := simple.NewSimple(hl)
-		if err = ui.Start(); err != nil {
-			panic(err)
+		if nt != nil {
+			eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, nt.HandleMsg)
 		}
+		eventHandler.Subscribe(eh, core.TopicRedPacket, auto.HandleRedPacket)
+		eventHandler.Subscribe(eh, core.TopicRedPacketStatus, auto.HandleStatus)
+
+		ws := session.NewWsService("chatroom", fishPiSdk.GetWsUrl, conf.Settings.WsInterval, eh, loger).
+			SetOutbound(hl.KeepLive())
+		onReload(bus, ws, hl.SetCacheNum)
+		sess.Add(ws.WithInput(hl.HandleInput))
+	}
+
+	// 小冰游戏
+	if *iceMode {
+		eh := bus.Namespace("ice")
+
+		// 初始化消息处理器
+		hl := ice.NewCore(conf.Ice.Ck, conf.Ice.Username, conf.Ice.Uid, display, loger)
+		hl.SetUpdateCKFunc(conf.UpdateCK)
+
+		eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, hl.HandleMsg)
+		eventHandler.Subscribe(eh, eventHandler.TopicWsStatus, hl.HandleWsStatusMsg)
+
+		addr := func() (string, error) { return conf.Ice.Url, nil }
+		ws := session.NewWsService("ice", addr, conf.Settings.WsInterval, eh, loger).
+			SetOutbound(hl.KeepLive())
+		onReload(bus, ws, nil)
+		sess.Add(ws.WithInput(hl.HandleInput))
+	}
+
+	// 私聊模式
+	if *chatUser != "" {
+		eh := bus.Namespace("chat")
+
+		hl := core.NewChatHandler(*chatUser, eh, display, loger)
+		eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, hl.HandleMsg)
+		eventHandler.Subscribe(eh, eventHandler.TopicWsStatus, hl.HandleWsStatusMsg)
+
+		addr := func() (string, error) { return fishPiSdk.GetChatChannelUrl(*chatUser) }
+		ws := session.NewWsService("chat", addr, conf.Settings.WsInterval, eh, loger)
+		onReload(bus, ws, nil)
+		sess.Add(ws.WithInput(hl.HandleInput))
+	}
+
+	// 用户通知
+	if *notice {
+		eh := bus.Namespace("user")
+
+		hl := core.NewUserChannelHandler(display, loger)
+		eventHandler.Subscribe(eh, eventHandler.TopicWsMsg, hl.HandleMsg)
+		eventHandler.Subscribe(eh, eventHandler.TopicWsStatus, hl.HandleWsStatusMsg)
+
+		ws := session.NewWsService("user", fishPiSdk.GetUserChannelUrl, conf.Settings.WsInterval, eh, loger)
+		onReload(bus, ws, nil)
+		sess.Add(ws)
+	}
+
+	if sessionMode() {
+		run(sess, loger)
+		return
 	}
 
 	// 默认输出帮助信息
 	flag.PrintDefaults()
 }
+
+// sessionMode 是否开启了simple以外的运行模式 开启时运行会话 否则输出帮助信息
+func sessionMode() bool {
+	return *wsMode || *replMode || *iceMode || *message || *chatUser != "" || *notice
+}
+
+// onReload 配置重新加载后更新重连间隔和消息缓存数量
+func onReload(bus *eventHandler.Bus, ws *session.WsService, setCacheNum func(int)) {
+	eventHandler.Subscribe(bus, config.TopicReload, func(r *config.Reload) {
+		if r.Err != nil {
+			return
+		}
+		ws.SetInterval(r.New.Settings.WsInterval)
+		if setCacheNum != nil {
+			setCacheNum(r.New.Settings.MsgCacheNum)
+		}
+	})
+}
+
+// newLogger 根据配置创建诊断日志 simple模式独占终端 未配置日志文件时不输出
+func newLogger(c *config.Log, level *slog.LevelVar, quiet bool) (logger.Logger, io.Closer) {
+	// 配置已经校验过等级
+	l, _ := logger.ParseLevel(c.Level)
+	level.Set(l)
+
+	o := logger.Options{
+		Level:      level,
+		File:       c.File,
+		MaxSize:    c.MaxSize,
+		MaxBackups: c.MaxBackups,
+		MaxAge:     c.MaxAge,
+		Compress:   c.Compress,
+	}
+	if quiet {
+		o.Writer = io.Discard
+	}
+	return logger.New(o)
+}
+
+// notifyOptions 把配置文件中的提醒配置转换为notify的参数
+func notifyOptions(conf *config.Config) notify.Options {
+	n := conf.Notify
+	return notify.Options{
+		Username:   conf.FishPi.Username,
+		Mention:    n.Mention,
+		Keywords:   n.Keywords,
+		WatchUsers: n.WatchUsers,
+		Notifiers:  n.Notifiers,
+		Command:    n.Command,
+		Webhook:    n.Webhook,
+		QuietHours: n.QuietHours,
+		RateLimit:  time.Duration(n.RateLimit) * time.Second,
+	}
+}
+
+// transformOptions 把配置文件中的改写插件配置转换为transform的参数
+func transformOptions(conf *config.Config) transform.Options {
+	return transform.Options{
+		Disable:   conf.Transform.Disable,
+		KaibaiUrl: conf.Transform.KaibaiUrl,
+		Token:     conf.Elves.Token,
+	}
+}
+
+// openArchive 打开聊天记录存档 关闭存档或者打开失败时返回nil
+func openArchive(conf *config.Config, loger logger.Logger) *archive.Archive {
+	path := conf.ArchivePath()
+	if path == "" {
+		return nil
+	}
+	retention := time.Duration(conf.Archive.RetentionDays) * 24 * time.Hour
+	arc, err := archive.Open(path, retention, loger)
+	if err != nil {
+		loger.Warn("聊天记录存档不可用", "err", err)
+		return nil
+	}
+	return arc
+}
+
+// run 运行会话直到收到退出信号
+func run(sess *session.Session, loger logger.Logger) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := sess.Run(ctx); err != nil {
+		loger.Error("运行失败", "err", err)
+	}
+}
@@ -3,13 +3,17 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fishpi/config"
 	"fishpi/logger"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gdamore/tcell/v2"
 	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
 	"github.com/rivo/tview"
+	"log/slog"
 	"net/url"
+	"os"
 	"regexp"
 	"strings"
 	"testing"
@@ -20,6 +24,12 @@ const corporate = `["index"][darkcyan]这是一的内容[#b4b4b4][""]
 ["second"][#bbbbbb]这是二的内容[""]`
 
 func TestTView(t *testing.T) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		t.Skipf("no tty: %s", err)
+	}
+	_ = tty.Close()
+
 	app := tview.NewApplication()
 	textView := tview.NewTextView().
 		SetDynamicColors(true).
@@ -83,9 +93,8 @@ func TestIframeWeather(t *testing.T) {
 		}
 
 		buffer := bytes.NewBufferString(msg)
-		table := tablewriter.NewWriter(buffer)
-		table.SetHeader(strings.Split(u.Query().Get("date"), ","))
-		table.SetColumnAlignment([]int{tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER})
+		table := tablewriter.NewTable(buffer, tablewriter.WithHeaderAlignment(tw.AlignCenter), tablewriter.WithRowAlignment(tw.AlignCenter))
+		table.Header(strings.Split(u.Query().Get("date"), ","))
 
 		for _, v := range data {
 			table.Append(v)
@@ -150,9 +159,8 @@ func TestJsonWeather(t *testing.T) {
 
 	msg = fmt.Sprintf("%s天气\n", weather.T)
 	buffer := bytes.NewBufferString(msg)
-	table := tablewriter.NewWriter(buffer)
-	table.SetHeader(strings.Split(weather.Date, ","))
-	table.SetColumnAlignment([]int{tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER})
+	table := tablewriter.NewTable(buffer, tablewriter.WithHeaderAlignment(tw.AlignCenter), tablewriter.WithRowAlignment(tw.AlignCenter))
+	table.Header(strings.Split(weather.Date, ","))
 
 	for _, v := range data {
 		table.Append(v)
@@ -222,6 +230,43 @@ type Weather struct {
 */
 
 func TestLogger(t *testing.T) {
-	l := logger.New()
-	l.Debug("问题出现了")
+	l, m := logger.NewMemory(slog.LevelDebug)
+	l.Named("test").Debug("问题出现了", "code", 1)
+
+	r, ok := m.Find("问题出现了")
+	if !ok || r.Level != slog.LevelDebug || r.Attrs["component"] != "test" || r.Attrs["code"] != int64(1) {
+		t.Fatalf("unexpected records: %+v", m.Records())
+	}
+}
+
+func TestSessionMode(t *testing.T) {
+	defer func(repl, ws, msg bool) {
+		*replMode, *wsMode, *message = repl, ws, msg
+	}(*replMode, *wsMode, *message)
+
+	// repl模式会清除-ws和-msg 仍然需要运行会话而不是输出帮助信息
+	*replMode, *wsMode, *message = true, false, false
+	if !sessionMode() {
+		t.Error("-repl 应当运行会话")
+	}
+
+	*replMode = false
+	if sessionMode() {
+		t.Error("没有指定模式时应当输出帮助信息")
+	}
+}
+
+func TestValidateFeatures(t *testing.T) {
+	c := &config.Config{
+		Notify:    &config.Notify{Notifiers: []string{"bell", "popup", "command"}, QuietHours: "25:00-07:00"},
+		RedPacket: &config.RedPacket{Rules: []string{"random delay:3s-1s"}},
+		Transform: &config.Transform{Disable: []string{"markdown"}},
+		Log:       &config.Log{Level: "verbose"},
+	}
+	problems := strings.Join(validateFeatures(c), "\n")
+	for _, field := range []string{"log.level", "notify.notifiers", "notify.command", "notify.quietHours", "redPacket.rules", "transform.disable"} {
+		if !strings.Contains(problems, field) {
+			t.Errorf("missing problem for %s:\n%s", field, problems)
+		}
+	}
 }
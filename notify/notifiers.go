@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"fishpi/logger"
+)
+
+// bell 终端响铃
+type bell struct {
+	out io.Writer
+}
+
+func newBell() *bell {
+	return &bell{out: os.Stdout}
+}
+
+func (b *bell) Notify(ctx context.Context, e *Event) error {
+	_, err := io.WriteString(b.out, "\a")
+	return err
+}
+
+// highlight 在终端中输出一行反色的提醒
+type highlight struct {
+	display logger.Display
+	ansi    bool
+}
+
+func (h *highlight) Notify(ctx context.Context, e *Event) error {
+	msg := "🔔 " + e.String()
+	if h.ansi {
+		msg = "\x1b[7m" + msg + "\x1b[0m"
+	}
+	h.display.Print(msg)
+	return nil
+}
+
+// command 通过shell执行配置的命令 提醒内容通过环境变量传入 避免内容被当作命令执行
+// 例如 notify-send "摸鱼派" "$FISHPI_NOTIFY_TEXT"
+type command struct {
+	command string
+}
+
+func (c *command) Notify(ctx context.Context, e *Event) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", c.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.command)
+	}
+	cmd.Env = append(os.Environ(),
+		"FISHPI_NOTIFY_RULE="+e.Rule,
+		"FISHPI_NOTIFY_USER="+e.UserName,
+		"FISHPI_NOTIFY_NICKNAME="+e.Nickname,
+		"FISHPI_NOTIFY_CONTENT="+e.Content,
+		"FISHPI_NOTIFY_OID="+e.OId,
+		"FISHPI_NOTIFY_TEXT="+e.String(),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// webhook 以json推送提醒
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhook(url string) *webhook {
+	return &webhook{url: url, client: &http.Client{Timeout: notifyTimeout}}
+}
+
+func (w *webhook) Notify(ctx context.Context, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook返回 %s", resp.Status)
+	}
+	return nil
+}
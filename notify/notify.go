@@ -0,0 +1,245 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"fishpi/block"
+	"fishpi/logger"
+)
+
+// 提醒方式
+const (
+	Bell      = "bell"      // 终端响铃
+	Highlight = "highlight" // 高亮显示一行提醒 免打扰时段也会显示
+	Command   = "command"   // 执行配置的命令 例如 notify-send
+	Webhook   = "webhook"   // 以json推送到配置的地址
+)
+
+// Names 支持的提醒方式
+var Names = []string{Bell, Highlight, Command, Webhook}
+
+// notifyTimeout 执行命令和推送webhook的超时时间
+const notifyTimeout = 10 * time.Second
+
+// Options 提醒配置 由配置文件转换而来 修改后通过Update生效
+type Options struct {
+	Username   string        // 自己的用户名 用于识别@自己 自己发送的消息不提醒
+	Mention    bool          // 有人@自己时提醒
+	Keywords   []string      // 消息包含这些关键词时提醒 不区分大小写
+	WatchUsers []string      // 这些用户发言时提醒
+	Notifiers  []string      // 提醒方式 见 Names
+	Command    string        // command提醒执行的命令
+	Webhook    string        // webhook提醒推送的地址
+	QuietHours string        // 免打扰时段 例如 23:00-07:00 期间只高亮显示
+	RateLimit  time.Duration // 同一条规则两次提醒的最小间隔 0为不限制
+}
+
+// Event 一次提醒 同时作为webhook推送的内容
+type Event struct {
+	Rule     string    `json:"rule"` // 触发的规则 mention keyword:{关键词} user:{用户名}
+	OId      string    `json:"oId"`
+	UserName string    `json:"userName"`
+	Nickname string    `json:"userNickname"`
+	Content  string    `json:"content"`
+	Time     time.Time `json:"time"`
+}
+
+func (e *Event) String() string {
+	reason := "@了你"
+	if keyword, ok := strings.CutPrefix(e.Rule, "keyword:"); ok {
+		reason = "提到了 " + keyword
+	} else if strings.HasPrefix(e.Rule, "user:") {
+		reason = "发言了"
+	}
+	return fmt.Sprintf("%s(%s) %s：%s", e.Nickname, e.UserName, reason, e.Content)
+}
+
+// Notifier 一种提醒方式
+type Notifier interface {
+	Notify(ctx context.Context, e *Event) error
+}
+
+// wsMsg 聊天室ws消息中判断提醒需要的字段
+type wsMsg struct {
+	Type         string `json:"type"`
+	OId          string `json:"oId"`
+	UserName     string `json:"userName"`
+	UserNickname string `json:"userNickname"`
+	Client       string `json:"client"`
+	Content      string `json:"content"`
+	Md           string `json:"md"`
+}
+
+// Notify 根据@、关键词和关注的用户发送提醒 每条规则单独限流
+type Notify struct {
+	display logger.Display
+	ansi    bool
+
+	mu        sync.Mutex
+	opts      Options
+	quiet     *QuietHours
+	notifiers map[string]Notifier
+	last      map[string]time.Time // 每条规则上次提醒的时间
+
+	blocks *block.List
+	now    func() time.Time
+	logger logger.Logger
+}
+
+// New display用于高亮显示提醒 配置错误时返回错误
+func New(opts Options, display logger.Display, logger logger.Logger) (*Notify, error) {
+	n := &Notify{
+		display: display,
+		ansi:    true,
+		last:    make(map[string]time.Time),
+		now:     time.Now,
+		logger:  logger.Named("notify"),
+	}
+	if err := n.Update(opts); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// SetDisplay 更换高亮显示的位置 ansi为false时不输出终端颜色 例如simple模式 重新加载配置后仍然生效
+func (n *Notify) SetDisplay(display logger.Display, ansi bool) *Notify {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.display, n.ansi = display, ansi
+	// 只替换已经配置的高亮提醒
+	if _, ok := n.notifiers[Highlight]; ok {
+		n.notifiers[Highlight] = &highlight{display: display, ansi: ansi}
+	}
+	return n
+}
+
+// SetBlocks 被屏蔽的消息不提醒
+func (n *Notify) SetBlocks(blocks *block.List) *Notify {
+	n.blocks = blocks
+	return n
+}
+
+// Update 更新配置 出错时继续使用之前的配置 限流记录保留
+func (n *Notify) Update(opts Options) error {
+	quiet, err := ParseQuietHours(opts.QuietHours)
+	if err != nil {
+		return err
+	}
+	if opts.RateLimit < 0 {
+		return fmt.Errorf("提醒间隔不能小于0：%s", opts.RateLimit)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	notifiers := make(map[string]Notifier, len(opts.Notifiers))
+	for _, name := range opts.Notifiers {
+		switch name {
+		case Bell:
+			notifiers[name] = newBell()
+		case Highlight:
+			notifiers[name] = &highlight{display: n.display, ansi: n.ansi}
+		case Command:
+			if opts.Command == "" {
+				return fmt.Errorf("使用 %s 提醒时需要配置命令", Command)
+			}
+			notifiers[name] = &command{command: opts.Command}
+		case Webhook:
+			if opts.Webhook == "" {
+				return fmt.Errorf("使用 %s 提醒时需要配置地址", Webhook)
+			}
+			notifiers[name] = newWebhook(opts.Webhook)
+		default:
+			return fmt.Errorf("未知的提醒方式：%s 可选 %s", name, strings.Join(Names, "/"))
+		}
+	}
+	n.opts, n.quiet, n.notifiers = opts, quiet, notifiers
+	return nil
+}
+
+// HandleMsg 处理聊天室ws消息 只有聊天消息会触发提醒
+func (n *Notify) HandleMsg(bytes []byte) {
+	var m wsMsg
+	if err := json.Unmarshal(bytes, &m); err != nil {
+		n.logger.Warn("parse message failed", "err", err, "body", string(bytes))
+		return
+	}
+	if m.Type != "msg" {
+		return
+	}
+	content := m.Md
+	if content == "" {
+		content = m.Content
+	}
+	if n.blocks.Block(&block.Message{Type: m.Type, UserName: m.UserName, Client: m.Client, Content: content}) {
+		return
+	}
+
+	e := &Event{OId: m.OId, UserName: m.UserName, Nickname: m.UserNickname, Content: content, Time: n.now()}
+	notifiers := n.match(e)
+	if len(notifiers) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	for name, notifier := range notifiers {
+		if err := notifier.Notify(ctx, e); err != nil {
+			n.logger.Warn("提醒失败", "notifier", name, "rule", e.Rule, "err", err)
+		}
+	}
+}
+
+// match 找到第一条未被限流的规则 填写e.Rule并返回需要使用的提醒方式
+func (n *Notify) match(e *Event) map[string]Notifier {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.notifiers) == 0 || strings.EqualFold(e.UserName, n.opts.Username) {
+		return nil
+	}
+
+	for _, rule := range n.rules(e) {
+		if last, ok := n.last[rule]; ok && e.Time.Sub(last) < n.opts.RateLimit {
+			n.logger.Debug("提醒被限流", "rule", rule)
+			continue
+		}
+		n.last[rule] = e.Time
+		e.Rule = rule
+
+		if !n.quiet.Contains(e.Time) {
+			// 复制一份 调用方在锁外遍历
+			return maps.Clone(n.notifiers)
+		}
+		// 免打扰时段只高亮显示
+		if h, ok := n.notifiers[Highlight]; ok {
+			return map[string]Notifier{Highlight: h}
+		}
+		return nil
+	}
+	return nil
+}
+
+// rules 消息满足的规则 调用时需要持有锁
+func (n *Notify) rules(e *Event) []string {
+	var rules []string
+	content := strings.ToLower(e.Content)
+	if n.opts.Mention && n.opts.Username != "" && strings.Contains(content, "@"+strings.ToLower(n.opts.Username)) {
+		rules = append(rules, "mention")
+	}
+	for _, keyword := range n.opts.Keywords {
+		if keyword != "" && strings.Contains(content, strings.ToLower(keyword)) {
+			rules = append(rules, "keyword:"+keyword)
+		}
+	}
+	for _, user := range n.opts.WatchUsers {
+		if strings.EqualFold(user, e.UserName) {
+			rules = append(rules, "user:"+user)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fishpi/block"
+	"fishpi/logger"
+)
+
+type testDisplay struct {
+	lines []string
+}
+
+func (d *testDisplay) Printf(format string, a ...interface{}) {
+	d.Print(fmt.Sprintf(format, a...))
+}
+
+func (d *testDisplay) Print(msg string) {
+	d.lines = append(d.lines, msg)
+}
+
+func msg(user, md string) []byte {
+	body, _ := json.Marshal(wsMsg{Type: "msg", OId: "1", UserName: user, UserNickname: user, Md: md})
+	return body
+}
+
+func TestNotify(t *testing.T) {
+	var pushed []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		_ = json.NewDecoder(r.Body).Decode(&e)
+		pushed = append(pushed, e)
+	}))
+	defer srv.Close()
+
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	display := new(testDisplay)
+	n, err := New(Options{
+		Username:   "me",
+		Mention:    true,
+		Keywords:   []string{"红包"},
+		WatchUsers: []string{"Alice"},
+		Notifiers:  []string{Highlight, Webhook},
+		Webhook:    srv.URL,
+		QuietHours: "23:00-07:00",
+		RateLimit:  time.Minute,
+	}, display, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.SetDisplay(display, false)
+	blocks, _ := block.Open(filepath.Join(t.TempDir(), "blocks.json"), l)
+	n.SetBlocks(blocks)
+	if _, err = blocks.Add(&block.Rule{User: "spam"}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	n.now = func() time.Time { return now }
+
+	n.HandleMsg(msg("bob", "@ME 摸鱼吗"))
+	n.HandleMsg(msg("bob", "@me 再问一次")) // 被限流
+	n.HandleMsg(msg("bob", "发红包了"))
+	n.HandleMsg(msg("alice", "hi"))
+	n.HandleMsg(msg("me", "@me 红包"))   // 自己的消息
+	n.HandleMsg(msg("spam", "@me 红包")) // 被屏蔽
+	if len(pushed) != 3 || pushed[0].Rule != "mention" || pushed[1].Rule != "keyword:红包" || pushed[2].Rule != "user:Alice" {
+		t.Fatalf("pushed %+v", pushed)
+	}
+	if len(display.lines) != 3 || !strings.Contains(display.lines[0], "@了你") {
+		t.Fatalf("display %q", display.lines)
+	}
+
+	// 免打扰时段只高亮显示
+	now = time.Date(2024, 5, 1, 23, 30, 0, 0, time.Local)
+	n.HandleMsg(msg("bob", "@me 睡了吗"))
+	if len(pushed) != 3 || len(display.lines) != 4 {
+		t.Fatalf("quiet hours: pushed %d display %d", len(pushed), len(display.lines))
+	}
+
+	if err = n.Update(Options{Notifiers: []string{"unknown"}}); err == nil {
+		t.Error("未知的提醒方式应当报错")
+	}
+	if err = n.Update(Options{QuietHours: "23:00"}); err == nil {
+		t.Error("错误的免打扰时段应当报错")
+	}
+}
+
+func TestSetDisplay(t *testing.T) {
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	display, ui := new(testDisplay), new(testDisplay)
+	n, err := New(Options{Username: "me", Mention: true}, display, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有配置高亮时不开启
+	n.SetDisplay(ui, false)
+	n.HandleMsg(msg("bob", "@me 摸鱼吗"))
+	if len(ui.lines) != 0 || len(display.lines) != 0 {
+		t.Fatalf("highlight enabled without config: %q %q", ui.lines, display.lines)
+	}
+
+	// 重新加载配置后高亮仍然显示在更换后的位置
+	if err = n.Update(Options{Username: "me", Mention: true, Notifiers: []string{Highlight}}); err != nil {
+		t.Fatal(err)
+	}
+	n.HandleMsg(msg("bob", "@me 摸鱼吗"))
+	if len(ui.lines) != 1 || len(display.lines) != 0 {
+		t.Fatalf("display after update: %q %q", ui.lines, display.lines)
+	}
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuietHours 每天的免打扰时段 结束时间早于开始时间时跨越零点
+type QuietHours struct {
+	start, end int // 当天的分钟数
+}
+
+// ParseQuietHours 解析 23:00-07:00 格式的时段 为空时返回nil 表示没有免打扰时段
+func ParseQuietHours(s string) (*QuietHours, error) {
+	if s == "" {
+		return nil, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("免打扰时段格式应当是 23:00-07:00：%s", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return nil, fmt.Errorf("免打扰时段格式应当是 23:00-07:00：%s", s)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return nil, fmt.Errorf("免打扰时段格式应当是 23:00-07:00：%s", s)
+	}
+	return &QuietHours{start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains t是否在免打扰时段内 q为nil时始终返回false
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil || q.start == q.end {
+		return false
+	}
+	m := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return m >= q.start && m < q.end
+	}
+	return m >= q.start || m < q.end
+}
@@ -0,0 +1,219 @@
+package redpacket
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fishpi/logger"
+)
+
+// Entry 账本中的一条记录 每打开一个红包记录一条
+type Entry struct {
+	Time     time.Time `json:"time"`
+	OId      string    `json:"oId"`
+	Type     string    `json:"type,omitempty"` // 通过bridge等方式打开且红包不在最近的聊天记录中时未知
+	Sender   string    `json:"sender"`
+	Count    int       `json:"count"`
+	Got      int       `json:"got"`
+	Gain     int       `json:"gain"`     // 自己的收益 亏损为负
+	Received bool      `json:"received"` // 自己是否在领取名单中
+	Who      []Who     `json:"who"`      // 所有领取者
+}
+
+// Ledger 红包账本 以json lines追加写入文件 多个进程可以同时追加
+type Ledger struct {
+	path string
+	mu   sync.Mutex
+
+	logger logger.Logger
+}
+
+func NewLedger(path string, logger logger.Logger) *Ledger {
+	return &Ledger{path: path, logger: logger.Named("ledger")}
+}
+
+// Add 追加一条记录 l为nil时不记录
+func (l *Ledger) Add(e *Entry) error {
+	if l == nil {
+		return nil
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(body, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries 读取from之后的记录 from为零值时读取全部 无法解析的行会被跳过
+// 同一个红包重复打开时服务器会再次返回自己的收益 只保留第一条记录 类型未知时使用后面记录中的类型
+func (l *Ledger) Entries(from time.Time) ([]*Entry, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	seen := make(map[string]*Entry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			l.logger.Warn("跳过无法解析的账本记录", "err", err)
+			continue
+		}
+		if first, ok := seen[e.OId]; ok {
+			if first.Type == "" {
+				first.Type = e.Type
+			}
+			continue
+		}
+		seen[e.OId] = &e
+		if !e.Time.Before(from) {
+			entries = append(entries, &e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Stat 一组记录的统计
+type Stat struct {
+	Key   string
+	Count int
+	Net   int // 净收益
+}
+
+// Report 红包收益报表
+type Report struct {
+	Total   Stat
+	Days    []*Stat // 按日期升序
+	Types   []*Stat // 按净收益降序
+	Senders []*Stat // 按净收益降序 第一个为最佳 最后一个为最差
+}
+
+// NewReport 统计记录
+func NewReport(entries []*Entry) *Report {
+	r := &Report{Total: Stat{Key: "合计"}}
+	days, types, senders := map[string]*Stat{}, map[string]*Stat{}, map[string]*Stat{}
+	add := func(m map[string]*Stat, key string, e *Entry) {
+		s, ok := m[key]
+		if !ok {
+			s = &Stat{Key: key}
+			m[key] = s
+		}
+		s.Count++
+		s.Net += e.Gain
+	}
+	for _, e := range entries {
+		r.Total.Count++
+		r.Total.Net += e.Gain
+		add(days, e.Time.Local().Format("2006-01-02"), e)
+		add(types, TypeName(e.Type), e)
+		add(senders, e.Sender, e)
+	}
+
+	r.Days = sorted(days, func(a, b *Stat) bool { return a.Key < b.Key })
+	byNet := func(a, b *Stat) bool {
+		if a.Net != b.Net {
+			return a.Net > b.Net
+		}
+		return a.Key < b.Key
+	}
+	r.Types = sorted(types, byNet)
+	r.Senders = sorted(senders, byNet)
+	return r
+}
+
+func sorted(m map[string]*Stat, less func(a, b *Stat) bool) []*Stat {
+	list := make([]*Stat, 0, len(m))
+	for _, s := range m {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return less(list[i], list[j]) })
+	return list
+}
+
+func (r *Report) String() string {
+	if r.Total.Count == 0 {
+		return "还没有打开过红包"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "共打开%d个红包 净收益%d积分", r.Total.Count, r.Total.Net)
+	section := func(title string, list []*Stat) {
+		sb.WriteString("\n" + title + "：")
+		for _, s := range list {
+			fmt.Fprintf(&sb, "\n - %s %d个 %+d", s.Key, s.Count, s.Net)
+		}
+	}
+	section("每日", r.Days)
+	section("类型", r.Types)
+	if n := len(r.Senders); n != 0 {
+		best, worst := r.Senders[0], r.Senders[n-1]
+		fmt.Fprintf(&sb, "\n最佳：%s %+d 最差：%s %+d", best.Key, best.Net, worst.Key, worst.Net)
+	}
+	section("发送者", r.Senders)
+	return sb.String()
+}
+
+// WriteCSV 导出报表 每行为 分组,名称,个数,净收益
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{{"group", "key", "count", "net"}}
+	add := func(group string, list ...*Stat) {
+		for _, s := range list {
+			rows = append(rows, []string{group, s.Key, strconv.Itoa(s.Count), strconv.Itoa(s.Net)})
+		}
+	}
+	add("total", &r.Total)
+	add("day", r.Days...)
+	add("type", r.Types...)
+	add("sender", r.Senders...)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// TypeName 红包类型的中文名称
+func TypeName(t string) string {
+	switch t {
+	case TypeRandom:
+		return "拼手气红包"
+	case TypeAverage:
+		return "平分红包"
+	case TypeSpecify:
+		return "专属红包"
+	case TypeHeartbeat:
+		return "心跳红包"
+	case TypeRockPaperScissors:
+		return "猜拳红包"
+	case "":
+		return "未知"
+	default:
+		return t
+	}
+}
@@ -0,0 +1,86 @@
+package redpacket
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fishpi/logger"
+)
+
+func TestLedger(t *testing.T) {
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	ledger := NewLedger(filepath.Join(t.TempDir(), "redpackets.jsonl"), l)
+
+	day1 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	for _, e := range []*Entry{
+		{Time: day1, OId: "1", Type: TypeRandom, Sender: "alice", Gain: 10, Received: true},
+		{Time: day1, OId: "2", Type: TypeHeartbeat, Sender: "bob", Gain: -5, Received: true},
+		{Time: day2, OId: "3", Type: TypeRandom, Sender: "alice", Gain: 3, Received: true},
+		{Time: day2, OId: "4", Sender: "carol"},
+	} {
+		if err := ledger.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := ledger.Entries(time.Time{})
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("entries %d %v", len(entries), err)
+	}
+	if entries, _ = ledger.Entries(time.Date(2024, 5, 2, 0, 0, 0, 0, time.Local)); len(entries) != 2 {
+		t.Errorf("entries from day2: %d", len(entries))
+	}
+
+	entries, _ = ledger.Entries(time.Time{})
+	r := NewReport(entries)
+	if r.Total.Count != 4 || r.Total.Net != 8 {
+		t.Errorf("total %+v", r.Total)
+	}
+	if len(r.Days) != 2 || r.Days[0].Net != 5 || r.Days[1].Net != 3 {
+		t.Errorf("days %+v %+v", r.Days[0], r.Days[1])
+	}
+	if best, worst := r.Senders[0], r.Senders[len(r.Senders)-1]; best.Key != "alice" || best.Net != 13 || worst.Key != "bob" {
+		t.Errorf("best %+v worst %+v", best, worst)
+	}
+	if r.Types[len(r.Types)-1].Key != "心跳红包" || !strings.Contains(r.String(), "未知") {
+		t.Errorf("report %s", r)
+	}
+
+	var buf bytes.Buffer
+	if err = r.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 1+1+2+3+3 || lines[1] != "total,合计,4,8" {
+		t.Errorf("csv %q", lines)
+	}
+}
+
+func TestLedgerDuplicate(t *testing.T) {
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	ledger := NewLedger(filepath.Join(t.TempDir(), "redpackets.jsonl"), l)
+
+	// 通过bridge打开后又在终端打开了一次 服务器再次返回了自己的收益
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	for _, e := range []*Entry{
+		{Time: day, OId: "1", Sender: "alice", Gain: 10, Received: true},
+		{Time: day.Add(time.Minute), OId: "1", Type: TypeRandom, Sender: "alice", Gain: 10, Received: true},
+		{Time: day.Add(time.Minute), OId: "2", Type: TypeAverage, Sender: "bob", Gain: 2, Received: true},
+	} {
+		if err := ledger.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := ledger.Entries(time.Time{})
+	if err != nil || len(entries) != 2 || entries[0].Type != TypeRandom {
+		t.Fatalf("entries %d %v", len(entries), err)
+	}
+	if r := NewReport(entries); r.Total.Count != 2 || r.Total.Net != 12 {
+		t.Errorf("total %+v", r.Total)
+	}
+}
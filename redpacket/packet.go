@@ -0,0 +1,96 @@
+package redpacket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Who 红包的一个领取者
+type Who struct {
+	UserName  string `json:"userName"`
+	UserMoney int    `json:"userMoney"`
+	Time      string `json:"time,omitempty"`
+}
+
+// Packet 判断是否打开红包需要的信息 由聊天室的红包消息转换而来
+type Packet struct {
+	OId       string
+	Type      string
+	Sender    string
+	Receivers string // 专属红包的接收者 json数组或者逗号分隔的用户名
+	Money     int
+	Count     int
+	Got       int
+	Who       []Who
+}
+
+// Exhausted 是否已经被领完
+func (p *Packet) Exhausted() bool {
+	return p.Count > 0 && p.Got >= p.Count
+}
+
+// PerSlot 剩余积分平均到每个剩余名额
+func (p *Packet) PerSlot() int {
+	slots := p.Count - max(p.Got, len(p.Who))
+	if slots <= 0 {
+		return 0
+	}
+	if p.Type == TypeAverage {
+		return p.Money / max(p.Count, 1)
+	}
+	remaining := p.Money
+	for _, w := range p.Who {
+		remaining -= w.UserMoney
+	}
+	return max(remaining, 0) / slots
+}
+
+// Risk 心跳红包已领取者中亏损的比例 还没有人领取时为0
+func (p *Packet) Risk() float64 {
+	if len(p.Who) == 0 {
+		return 0
+	}
+	lost := 0
+	for _, w := range p.Who {
+		if w.UserMoney < 0 {
+			lost++
+		}
+	}
+	return float64(lost) / float64(len(p.Who))
+}
+
+// For 专属红包是否发给了username
+func (p *Packet) For(username string) bool {
+	var names []string
+	if err := json.Unmarshal([]byte(p.Receivers), &names); err != nil {
+		names = strings.Split(p.Receivers, ",")
+	}
+	for _, name := range names {
+		if strings.EqualFold(strings.TrimSpace(name), username) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decide 根据规则判断是否打开红包 不打开时返回原因 username为自己的用户名
+func Decide(r *Rule, p *Packet, username string) (bool, string) {
+	switch {
+	case r == nil:
+		return false, "没有该类型红包的规则"
+	case !r.Enable:
+		return false, "该类型红包的规则已关闭"
+	case strings.EqualFold(p.Sender, username):
+		return false, "自己发的红包"
+	case p.Exhausted():
+		return false, "红包已经被领完"
+	case p.Type == TypeSpecify && !p.For(username):
+		return false, "专属红包不是发给自己的"
+	case p.Type == TypeHeartbeat && p.Risk() > r.MaxRisk:
+		return false, fmt.Sprintf("心跳红包风险过高 %.2f > %.2f", p.Risk(), r.MaxRisk)
+	case r.MinPerSlot > 0 && p.PerSlot() < r.MinPerSlot:
+		return false, fmt.Sprintf("剩余名额平均积分过低 %d < %d", p.PerSlot(), r.MinPerSlot)
+	}
+	return true, ""
+}
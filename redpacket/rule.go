@@ -0,0 +1,150 @@
+package redpacket
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 红包类型 与聊天室红包消息的type一致
+const (
+	TypeRandom            = "random"            // 拼手气红包
+	TypeAverage           = "average"           // 平分红包
+	TypeSpecify           = "specify"           // 专属红包
+	TypeHeartbeat         = "heartbeat"         // 心跳红包
+	TypeRockPaperScissors = "rockPaperScissors" // 猜拳红包
+)
+
+// Types 支持自动打开的红包类型
+var Types = []string{TypeRandom, TypeAverage, TypeSpecify, TypeHeartbeat, TypeRockPaperScissors}
+
+// 猜拳红包的出拳 与open指令一致 0为随机
+var gestures = map[string]string{"random": "0", "rock": "1", "scissors": "2", "paper": "3", "0": "0", "1": "1", "2": "2", "3": "3"}
+
+// Rule 一种红包的自动打开规则
+type Rule struct {
+	Type       string
+	Enable     bool
+	MinDelay   time.Duration // 打开前随机等待 模拟手动打开
+	MaxDelay   time.Duration
+	MinPerSlot int     // 剩余积分平均到每个剩余名额低于该值时不打开 0为不限制
+	MaxRisk    float64 // 心跳红包 已领取者中亏损的比例超过该值时不打开
+	Gesture    string  // 猜拳红包的出拳 0-随机 1-石头 2-剪刀 3-布
+}
+
+// ParseRule 解析一条规则 例如 random delay:1s-3s min:5 或者 heartbeat maxRisk:0.3 或者 specify off
+func ParseRule(s string) (*Rule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, errors.New("红包规则不能为空")
+	}
+	r := &Rule{Type: parseType(fields[0]), Enable: true, MaxRisk: 0.5, Gesture: "0"}
+	if r.Type == "" {
+		return nil, fmt.Errorf("红包类型应当是 %s：%s", strings.Join(Types, "/"), fields[0])
+	}
+	for _, arg := range fields[1:] {
+		name, value, _ := strings.Cut(arg, ":")
+		var err error
+		switch strings.ToLower(name) {
+		case "on":
+			r.Enable = true
+		case "off":
+			r.Enable = false
+		case "delay":
+			err = r.parseDelay(value)
+		case "min":
+			if r.MinPerSlot, err = strconv.Atoi(value); err == nil && r.MinPerSlot < 0 {
+				err = errors.New("不能小于0")
+			}
+		case "maxrisk":
+			if r.MaxRisk, err = strconv.ParseFloat(value, 64); err == nil && (r.MaxRisk < 0 || r.MaxRisk > 1) {
+				err = errors.New("应当在0到1之间")
+			}
+		case "gesture":
+			var ok bool
+			if r.Gesture, ok = gestures[strings.ToLower(value)]; !ok {
+				err = errors.New("应当是 random/rock/scissors/paper")
+			}
+		default:
+			return nil, fmt.Errorf("未知的条件：%s 可选 on off delay min maxRisk gesture", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s：%w", r.Type, arg, err)
+		}
+	}
+	return r, nil
+}
+
+func parseType(s string) string {
+	for _, t := range Types {
+		if strings.EqualFold(s, t) {
+			return t
+		}
+	}
+	return ""
+}
+
+// parseDelay 1s-3s 或者 2s
+func (r *Rule) parseDelay(value string) (err error) {
+	from, to, ok := strings.Cut(value, "-")
+	if r.MinDelay, err = time.ParseDuration(from); err != nil {
+		return errors.New("时长格式错误 例如 1s-3s")
+	}
+	r.MaxDelay = r.MinDelay
+	if ok {
+		if r.MaxDelay, err = time.ParseDuration(to); err != nil {
+			return errors.New("时长格式错误 例如 1s-3s")
+		}
+	}
+	if r.MinDelay < 0 || r.MaxDelay < r.MinDelay {
+		return errors.New("时长范围错误")
+	}
+	return nil
+}
+
+// ParseRules 解析所有规则 同一种红包只能有一条规则 没有规则的红包不自动打开
+func ParseRules(list []string) (map[string]*Rule, error) {
+	rules := make(map[string]*Rule, len(list))
+	for _, s := range list {
+		r, err := ParseRule(s)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := rules[r.Type]; ok {
+			return nil, fmt.Errorf("%s 的规则重复", r.Type)
+		}
+		rules[r.Type] = r
+	}
+	return rules, nil
+}
+
+// Delay 在规则的范围内随机等待时间
+func (r *Rule) Delay() time.Duration {
+	if r.MaxDelay <= r.MinDelay {
+		return r.MinDelay
+	}
+	return r.MinDelay + time.Duration(rand.Int63n(int64(r.MaxDelay-r.MinDelay)))
+}
+
+func (r *Rule) String() string {
+	s := r.Type
+	if !r.Enable {
+		return s + " off"
+	}
+	if r.MaxDelay > 0 {
+		s += fmt.Sprintf(" delay:%s-%s", r.MinDelay, r.MaxDelay)
+	}
+	if r.MinPerSlot > 0 {
+		s += fmt.Sprintf(" min:%d", r.MinPerSlot)
+	}
+	switch r.Type {
+	case TypeHeartbeat:
+		s += fmt.Sprintf(" maxRisk:%g", r.MaxRisk)
+	case TypeRockPaperScissors:
+		s += " gesture:" + r.Gesture
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package redpacket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecide(t *testing.T) {
+	rules, err := ParseRules([]string{
+		"random delay:1s-3s min:5",
+		"specify",
+		"heartbeat maxRisk:0.3",
+		"rockPaperScissors off gesture:paper",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := rules[TypeRandom]; r.MinDelay != time.Second || r.MaxDelay != 3*time.Second {
+		t.Errorf("delay %s-%s", r.MinDelay, r.MaxDelay)
+	}
+	if d := rules[TypeRandom].Delay(); d < time.Second || d >= 3*time.Second {
+		t.Errorf("delay out of range: %s", d)
+	}
+	if rules[TypeRockPaperScissors].Gesture != "3" {
+		t.Errorf("gesture %s", rules[TypeRockPaperScissors].Gesture)
+	}
+
+	cases := []struct {
+		p    Packet
+		open bool
+	}{
+		{Packet{Type: TypeRandom, Sender: "bob", Money: 100, Count: 10}, true},
+		{Packet{Type: TypeRandom, Sender: "bob", Money: 100, Count: 10, Got: 9, Who: []Who{{UserName: "a", UserMoney: 98}}}, false},
+		{Packet{Type: TypeRandom, Sender: "me", Money: 100, Count: 10}, false},
+		{Packet{Type: TypeRandom, Sender: "bob", Money: 100, Count: 1, Got: 1}, false},
+		{Packet{Type: TypeAverage, Sender: "bob", Money: 100, Count: 10}, false},
+		{Packet{Type: TypeSpecify, Sender: "bob", Receivers: `["alice","Me"]`, Money: 10, Count: 2}, true},
+		{Packet{Type: TypeSpecify, Sender: "bob", Receivers: `["alice"]`, Money: 10, Count: 1}, false},
+		{Packet{Type: TypeHeartbeat, Sender: "bob", Money: 10, Count: 5, Got: 2, Who: []Who{{UserName: "a", UserMoney: 5}, {UserName: "b", UserMoney: 3}}}, true},
+		{Packet{Type: TypeHeartbeat, Sender: "bob", Money: 10, Count: 5, Got: 2, Who: []Who{{UserName: "a", UserMoney: 5}, {UserName: "b", UserMoney: -3}}}, false},
+		{Packet{Type: TypeRockPaperScissors, Sender: "bob", Money: 10, Count: 1}, false},
+	}
+	for i, c := range cases {
+		if got, reason := Decide(rules[c.p.Type], &c.p, "me"); got != c.open {
+			t.Errorf("case %d: open = %v (%s), want %v", i, got, reason, c.open)
+		}
+	}
+
+	for _, s := range []string{"unknown", "random delay:3s-1s", "heartbeat maxRisk:2", "random size:1"} {
+		if _, err = ParseRule(s); err == nil {
+			t.Errorf("%q 应当报错", s)
+		}
+	}
+	if _, err = ParseRules([]string{"random", "random off"}); err == nil {
+		t.Error("重复的规则应当报错")
+	}
+}
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+	"fishpi/ws"
+)
+
+// WsService 基于websocket的服务 负责连接的建立、发送和关闭
+type WsService struct {
+	name     string
+	addr     func() (string, error)
+	interval atomic.Int64
+	outbound <-chan []byte
+
+	mu     sync.Mutex
+	client interface{ SetReconnectInterval(int) } // 当前的连接 未运行时为nil
+
+	eh     *eventHandler.Bus
+	logger logger.Logger
+}
+
+// NewWsService eh应当是该服务独占的命名空间 连接收到的消息发布在其中
+func NewWsService(name string, addr func() (string, error), interval int, eh *eventHandler.Bus, logger logger.Logger) *WsService {
+	s := &WsService{
+		name:   name,
+		addr:   addr,
+		eh:     eh,
+		logger: logger.With("service", name),
+	}
+	s.interval.Store(int64(interval))
+	return s
+}
+
+// SetOutbound 设置需要主动发送的消息来源 例如心跳
+func (s *WsService) SetOutbound(c <-chan []byte) *WsService {
+	s.outbound = c
+	return s
+}
+
+// WithInput 返回可以接收终端输入的服务 input处理终端输入 没有调用时该服务不会成为输入目标
+func (s *WsService) WithInput(input func(string)) Service {
+	return &inputWsService{WsService: s, input: input}
+}
+
+// SetInterval 修改断线重连的时间间隔 运行中也可以修改
+func (s *WsService) SetInterval(interval int) {
+	s.interval.Store(int64(interval))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		s.client.SetReconnectInterval(interval)
+	}
+}
+
+func (s *WsService) Name() string {
+	return s.name
+}
+
+func (s *WsService) Run(ctx context.Context) error {
+	u, err := s.addr()
+	if err != nil {
+		return err
+	}
+
+	client := ws.NewWs(u, int(s.interval.Load()), s.eh, s.logger)
+	s.mu.Lock()
+	s.client = client
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.client = nil
+		s.mu.Unlock()
+	}()
+
+	sub := eventHandler.Subscribe(s.eh, eventHandler.TopicWsSend, func(msg []byte) {
+		client.Send(msg)
+	})
+	defer sub.Unsubscribe()
+	if err = client.Start(); err != nil {
+		return err
+	}
+	s.logger.Info("已连接到节点", "addr", ws.Redact(u))
+
+	for {
+		select {
+		case msg := <-s.outbound:
+			client.Send(msg)
+		case <-ctx.Done():
+			_ = client.Stop()
+			return nil
+		}
+	}
+}
+
+// funcService 由函数构成的服务
+type funcService struct {
+	name  string
+	run   func(ctx context.Context) error
+	input func(string)
+}
+
+// NewService 创建一个普通服务 run应当阻塞直到ctx结束
+func NewService(name string, run func(ctx context.Context) error, input func(string)) Service {
+	f := &funcService{name: name, run: run, input: input}
+	if input == nil {
+		return f
+	}
+	return &inputService{f}
+}
+
+func (f *funcService) Name() string {
+	return f.name
+}
+
+func (f *funcService) Run(ctx context.Context) error {
+	return f.run(ctx)
+}
+
+type inputService struct {
+	*funcService
+}
+
+func (i *inputService) HandleInput(line string) {
+	i.input(line)
+}
+
+type inputWsService struct {
+	*WsService
+	input func(string)
+}
+
+func (i *inputWsService) HandleInput(line string) {
+	i.input(line)
+}
@@ -0,0 +1,177 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"fishpi/logger"
+)
+
+const (
+	focusPrefix = ":" // 切换输入目标的指令前缀 例如 `:ice`
+	inputBuffer = 64  // 每个服务排队等待处理的输入数量 超过时阻塞终端读取
+)
+
+// Service 会话中一个独立运行的连接 Run阻塞直到ctx结束或连接出错
+type Service interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// Inputer 可以接收终端输入的服务
+type Inputer interface {
+	HandleInput(line string)
+}
+
+// Session 在同一进程中托管多个连接 共用一个终端和一个事件总线
+type Session struct {
+	services []Service
+	inputs   map[string]*inputQueue
+	focus    string
+	stdin    bool
+	cancel   context.CancelFunc
+
+	mu      sync.Mutex
+	display logger.Display
+	logger  logger.Logger
+}
+
+func NewSession(display logger.Display, logger logger.Logger) *Session {
+	return &Session{
+		inputs:  make(map[string]*inputQueue),
+		stdin:   true,
+		display: display,
+		logger:  logger.Named("session"),
+	}
+}
+
+// Add 添加服务 第一个可以接收输入的服务作为默认输入目标
+func (s *Session) Add(svc Service) {
+	s.services = append(s.services, svc)
+	if in, ok := svc.(Inputer); ok {
+		s.inputs[svc.Name()] = &inputQueue{in: in, lines: make(chan string, inputBuffer)}
+		if s.focus == "" {
+			s.focus = svc.Name()
+		}
+	}
+}
+
+// DisableStdin 不读取终端输入 用于由UI接管终端的场景
+func (s *Session) DisableStdin() {
+	s.stdin = false
+}
+
+// Run 启动所有服务 直到ctx结束并且所有服务都退出
+func (s *Session) Run(ctx context.Context) error {
+	if len(s.services) == 0 {
+		return fmt.Errorf("没有需要运行的服务")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	for _, q := range s.inputs {
+		go q.run(ctx)
+	}
+
+	var wg sync.WaitGroup
+	for _, svc := range s.services {
+		wg.Add(1)
+		go func(svc Service) {
+			defer wg.Done()
+			if err := svc.Run(ctx); err != nil {
+				s.logger.Error("服务退出", "service", svc.Name(), "err", err)
+			}
+		}(svc)
+	}
+
+	if s.stdin && len(s.inputs) > 0 {
+		if len(s.inputs) > 1 {
+			s.display.Printf("当前输入目标：%s 输入 %s{name} 切换 可选：%s", s.focus, focusPrefix, strings.Join(s.inputNames(), " "))
+		}
+		go s.watch()
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// Stop 结束会话中的所有服务
+func (s *Session) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+func (s *Session) watch() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		s.dispatch(line)
+	}
+	if err := scanner.Err(); err != nil {
+		s.logger.Error("read stdin failed", "err", err)
+	}
+}
+
+func (s *Session) dispatch(line string) {
+	if strings.HasPrefix(line, focusPrefix) {
+		name := strings.TrimPrefix(line, focusPrefix)
+		s.mu.Lock()
+		_, ok := s.inputs[name]
+		if ok {
+			s.focus = name
+		}
+		s.mu.Unlock()
+		if !ok {
+			s.display.Printf("没有名为%s的服务 可选：%s", name, strings.Join(s.inputNames(), " "))
+			return
+		}
+		s.display.Printf("输入目标已切换到：%s", name)
+		return
+	}
+
+	s.mu.Lock()
+	q := s.inputs[s.focus]
+	s.mu.Unlock()
+	q.lines <- line
+}
+
+// inputQueue 一个服务的输入队列 按输入顺序逐条处理 处理较慢的服务不影响其他服务
+type inputQueue struct {
+	in    Inputer
+	lines chan string
+}
+
+func (q *inputQueue) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line := <-q.lines:
+			q.in.HandleInput(line)
+		}
+	}
+}
+
+func (s *Session) inputNames() []string {
+	var names []string
+	for _, svc := range s.services {
+		if _, ok := s.inputs[svc.Name()]; ok {
+			names = append(names, svc.Name())
+		}
+	}
+	return names
+}
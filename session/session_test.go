@@ -0,0 +1,128 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"fishpi/logger"
+)
+
+type testDisplay struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (d *testDisplay) Printf(format string, a ...interface{}) {
+	d.Print(fmt.Sprintf(format, a...))
+}
+
+func (d *testDisplay) Print(msg string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lines = append(d.lines, msg)
+}
+
+// recorder 记录收到的输入 全部收到后关闭done
+type recorder struct {
+	mu    sync.Mutex
+	lines []string
+	want  int
+	done  chan struct{}
+}
+
+func newRecorder(want int) *recorder {
+	return &recorder{want: want, done: make(chan struct{})}
+}
+
+func (r *recorder) input(line string) {
+	// 处理较慢的输入不能被后面的输入超过
+	if n, _ := strconv.Atoi(line); n%3 == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, line)
+	if len(r.lines) == r.want {
+		close(r.done)
+	}
+}
+
+func (r *recorder) wait(t *testing.T) []string {
+	t.Helper()
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for input")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lines
+}
+
+func TestDispatch(t *testing.T) {
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	display := new(testDisplay)
+	s := NewSession(display, l)
+	s.DisableStdin()
+
+	block := func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}
+	chatroom, ice := newRecorder(20), newRecorder(1)
+	s.Add(NewService("chatroom", block, chatroom.input))
+	s.Add(NewService("notice", block, nil))
+	s.Add(NewService("ice", block, ice.input))
+
+	stopped := make(chan struct{})
+	go func() {
+		_ = s.Run(context.Background())
+		close(stopped)
+	}()
+
+	// 默认输入目标为第一个可以输入的服务 按输入顺序处理
+	for i := 1; i <= 20; i++ {
+		s.dispatch(strconv.Itoa(i))
+	}
+	got := chatroom.wait(t)
+	for i, line := range got {
+		if line != strconv.Itoa(i+1) {
+			t.Fatalf("input out of order: %v", got)
+		}
+	}
+
+	s.dispatch(":notice")
+	s.dispatch(":ice")
+	s.dispatch("开始")
+	if got = ice.wait(t); got[0] != "开始" {
+		t.Errorf("ice got %v", got)
+	}
+
+	s.Stop()
+	<-stopped
+
+	display.mu.Lock()
+	defer display.mu.Unlock()
+	want := []string{"没有名为notice的服务 可选：chatroom ice", "输入目标已切换到：ice"}
+	if fmt.Sprint(display.lines) != fmt.Sprint(want) {
+		t.Errorf("display %q", display.lines)
+	}
+}
+
+func TestWsServiceInput(t *testing.T) {
+	l, _ := logger.NewMemory(slog.LevelWarn)
+	s := NewSession(new(testDisplay), l)
+	addr := func() (string, error) { return "", nil }
+	s.Add(NewWsService("user", addr, 3, nil, l))
+	s.Add(NewWsService("ice", addr, 3, nil, l).WithInput(func(string) {}))
+
+	// 只输出的服务不作为输入目标
+	if names := s.inputNames(); fmt.Sprint(names) != "[ice]" || s.focus != "ice" {
+		t.Errorf("inputs %v focus %s", names, s.focus)
+	}
+}
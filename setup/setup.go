@@ -0,0 +1,160 @@
+package setup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"fishpi/config"
+	"fishpi/core"
+	"fishpi/logger"
+)
+
+// Wizard 首次使用时的配置向导 登录获取apiKey后生成配置文件
+type Wizard struct {
+	path     string
+	in       *bufio.Reader
+	out      io.Writer
+	password func() (string, error) // 读取密码 终端中不回显
+	logger   logger.Logger
+}
+
+func NewWizard(path string, logger logger.Logger) *Wizard {
+	w := &Wizard{
+		path:   path,
+		in:     bufio.NewReader(os.Stdin),
+		out:    os.Stdout,
+		logger: logger,
+	}
+	w.password = w.readLine
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		w.password = func() (string, error) {
+			b, err := term.ReadPassword(fd)
+			fmt.Fprintln(w.out)
+			return string(b), err
+		}
+	}
+	return w
+}
+
+func (w *Wizard) Run() error {
+	if _, err := os.Stat(w.path); err == nil {
+		ok, err := w.confirm(fmt.Sprintf("配置文件 %s 已存在 是否覆盖 原文件会备份为 %s.bak.1", w.path, w.path))
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	apiBase, err := w.apiBase()
+	if err != nil {
+		return err
+	}
+	username, err := w.required("用户名：", w.readLine)
+	if err != nil {
+		return err
+	}
+	password, err := w.required("密码：", w.password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w.out, "二次验证码 没有开启可以直接回车：")
+	mfaCode, err := w.readLine()
+	if err != nil {
+		return err
+	}
+
+	f := &config.FishPi{ApiBase: apiBase, Username: username, Password: password}
+	f.Init()
+
+	api, err := core.NewApi(apiBase)
+	if err != nil {
+		return err
+	}
+	sdk := core.NewSdk(api, config.DefaultUserAgent, "", username, w.logger)
+	if err = sdk.GetKey(username, f.PasswordMd5, mfaCode); err != nil {
+		return fmt.Errorf("登录失败 %w", err)
+	}
+	f.ApiKey = sdk.GetApiKey()
+
+	// 使用新的apiKey确认登录成功
+	nickname, err := core.NewSdk(api, config.DefaultUserAgent, f.ApiKey, username, w.logger).User()
+	if err != nil {
+		return fmt.Errorf("apiKey校验失败 %w", err)
+	}
+	fmt.Fprintf(w.out, "登录成功 欢迎 %s(%s)\n", nickname, username)
+
+	// 密码MD5只保存在加密的密钥文件中 二次验证码只能使用一次 不保存
+	f.Password, f.MfaCode = "", ""
+	secrets, err := w.confirm(fmt.Sprintf("是否把apiKey保存到加密的密钥文件 口令可以通过环境变量 %s 传入", config.PassphraseEnv))
+	if err != nil {
+		return err
+	}
+	keep := false
+	if secrets {
+		if keep, err = w.confirm("是否同时保存密码MD5 apiKey过期后可以通过 -login 重新获取"); err != nil {
+			return err
+		}
+	}
+	if !keep {
+		f.PasswordMd5 = ""
+	}
+
+	if _, err = config.Create(w.path, f, secrets); err != nil {
+		return err
+	}
+	fmt.Fprintf(w.out, "配置文件已保存：%s\n", w.path)
+	return nil
+}
+
+// apiBase 选择接口地址 也可以直接输入
+func (w *Wizard) apiBase() (string, error) {
+	fmt.Fprintf(w.out, "接口地址\n  1) %s\n  2) %s 备用域名\n请选择或者直接输入地址 [1]：", config.DefaultApiBase, config.MirrorApiBase)
+	line, err := w.readLine()
+	if err != nil {
+		return "", err
+	}
+	switch line {
+	case "", "1":
+		return config.DefaultApiBase, nil
+	case "2":
+		return config.MirrorApiBase, nil
+	default:
+		return strings.TrimSuffix(line, "/"), nil
+	}
+}
+
+func (w *Wizard) required(prompt string, read func() (string, error)) (string, error) {
+	for {
+		fmt.Fprint(w.out, prompt)
+		line, err := read()
+		if err != nil {
+			return "", err
+		}
+		if line != "" {
+			return line, nil
+		}
+		fmt.Fprintln(w.out, "不能为空")
+	}
+}
+
+func (w *Wizard) confirm(prompt string) (bool, error) {
+	fmt.Fprintf(w.out, "%s (y/N)：", prompt)
+	line, err := w.readLine()
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(line, "y") || strings.EqualFold(line, "yes"), nil
+}
+
+func (w *Wizard) readLine() (string, error) {
+	line, err := w.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,103 @@
+package setup
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fishpi/config"
+	"fishpi/logger"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/getKey", func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&data)
+		if data["nameOrEmail"] != "test" || data["userPassword"] != "5f4dcc3b5aa765d61d8327deb882cf99" {
+			_, _ = io.WriteString(w, `{"code":-1,"msg":"密码错误"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"code":0,"Key":"new-key"}`)
+	})
+	mux.HandleFunc("GET /api/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apiKey") != "new-key" {
+			_, _ = io.WriteString(w, `{"code":-1,"msg":"invalid key"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"code":0,"data":{"userNickname":"测试","userName":"test"}}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWizard(t *testing.T) {
+	srv := newServer(t)
+	path := filepath.Join(t.TempDir(), "conf", "config.yaml")
+	w := &Wizard{
+		path:   path,
+		in:     bufio.NewReader(strings.NewReader(srv.URL + "\n\ntest\npassword\n123456\nn\n")),
+		out:    io.Discard,
+		logger: logger.NewLogger(os.Stderr, slog.LevelWarn),
+	}
+	w.password = w.readLine
+	if err := w.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("unexpected permission: %o", perm)
+	}
+	c, err := config.Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FishPi.ApiBase != srv.URL || c.FishPi.Username != "test" || c.FishPi.ApiKey != "new-key" {
+		t.Errorf("unexpected config: %+v", c.FishPi)
+	}
+	body, _ := os.ReadFile(path)
+	if !strings.Contains(string(body), `passwordMd5: ""`) || !strings.Contains(string(body), `mfaCode: ""`) || !strings.Contains(string(body), "# ws断线重连时间间隔") {
+		t.Errorf("unexpected config file:\n%s", body)
+	}
+}
+
+func TestWizardSecrets(t *testing.T) {
+	t.Setenv(config.PassphraseEnv, "passphrase")
+	srv := newServer(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	w := &Wizard{
+		path:   path,
+		in:     bufio.NewReader(strings.NewReader(srv.URL + "\n\ntest\npassword\n\ny\ny\n")),
+		out:    io.Discard,
+		logger: logger.NewLogger(os.Stderr, slog.LevelWarn),
+	}
+	w.password = w.readLine
+	if err := w.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 配置文件中没有明文的apiKey和密码MD5
+	body, _ := os.ReadFile(path)
+	if strings.Contains(string(body), "new-key") || strings.Contains(string(body), "5f4dcc3b5aa765d61d8327deb882cf99") {
+		t.Errorf("credentials written to config file:\n%s", body)
+	}
+	c, err := config.Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FishPi.ApiKey != "new-key" || c.FishPi.PasswordMd5 != "5f4dcc3b5aa765d61d8327deb882cf99" {
+		t.Errorf("secrets not loaded: %+v", c.FishPi)
+	}
+}
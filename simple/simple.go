@@ -3,7 +3,6 @@ package simple
 import (
 	"fishpi/core"
 	"fmt"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +17,7 @@ const (
 	pageUserChatroom   = "page-user-chatroom"   // 私聊
 	pageMoonList       = "page-moon-list"       // 明月清风
 	pageIceGame        = "page-ice-game"        // 小冰游戏
+	pageSearch         = "page-search"          // 搜索聊天记录
 	pageMessageMenu    = "page-message-menu"    // 信息菜单
 
 	actionMenu            = "action_menu"             // 打开菜单
@@ -41,6 +41,7 @@ type Simple struct {
 	pages       *tview.Pages
 	messageView *tview.TextView
 	infoView    *tview.TextView
+	inputView   *tview.InputField
 
 	// 内部数据
 	publicMessageChan chan *core.WsMsgReply
@@ -72,6 +73,7 @@ func NewSimple(c *core.Core) *Simple {
 	u.addUserChatroom()
 	u.addMoonList()
 	u.addIceGame()
+	u.addSearch()
 	u.makeUI()
 
 	return u
@@ -105,6 +107,9 @@ func (u *Simple) makeUI() {
 	list.AddItem("小冰游戏", "", 0, func() {
 		u.pages.SwitchToPage(pageIceGame)
 	})
+	list.AddItem("搜索", "", 0, func() {
+		u.pages.SwitchToPage(pageSearch)
+	})
 	list.SetCurrentItem(0)
 	list.SetMainTextStyle(tcell.StyleDefault)
 	list.SetBackgroundColor(tcell.ColorDefault)
@@ -176,7 +181,8 @@ func (u *Simple) addPublicChatroom() {
 
 	// 输入框
 	inputView := tview.NewInputField()
-	inputView.SetPlaceholder(" 这里输入你要发送的消息")
+	inputView.SetPlaceholder(" 这里输入你要发送的消息 输入/help查看指令")
+	u.inputView = inputView
 
 	style := tcell.StyleDefault
 	style = style.Background(tcell.NewRGBColor(43, 43, 43))
@@ -184,10 +190,29 @@ func (u *Simple) addPublicChatroom() {
 	inputView.SetPlaceholderStyle(style)
 	inputView.SetFieldStyle(style)
 
+	inputView.SetAutocompleteFunc(func(text string) []string {
+		line, ok := strings.CutPrefix(text, "/")
+		if !ok {
+			return nil
+		}
+		var entries []string
+		for _, v := range u.core.Complete(line) {
+			entries = append(entries, "/"+v)
+		}
+		return entries
+	})
 	inputView.SetDoneFunc(func(key tcell.Key) {
 		if key == tcell.KeyEnter {
-			if err := u.core.SendPublicMsg(inputView.GetText()); err != nil {
-				u.showInfo(fmt.Sprintf("send %s error: %s", inputView.GetText(), err))
+			text := inputView.GetText()
+			if line, ok := strings.CutPrefix(text, "/"); ok {
+				// 指令可能需要请求接口 不阻塞界面
+				go func() {
+					if err := u.core.Exec(line, u); err != nil {
+						u.showInfo(err.Error())
+					}
+				}()
+			} else if err := u.core.SendPublicMsg(text); err != nil {
+				u.showInfo(fmt.Sprintf("send %s error: %s", text, err))
 			}
 			inputView.SetText("")
 		}
@@ -230,6 +255,47 @@ func (u *Simple) addIceGame() {
 	u.pages.AddPage(pageIceGame, tview.NewBox().SetTitle(" 小冰游戏界面 ").SetBackgroundColor(tcell.ColorDefault).SetTitleAlign(tview.AlignRight).SetBorder(true), true, false)
 }
 
+func (u *Simple) addSearch() {
+	// 搜索结果
+	resultView := tview.NewTextView().
+		SetText("输入关键词后回车 支持 user:用户名 type:消息类型 from:2006-01-02 to:2006-01-02 limit:20").
+		SetWordWrap(true).
+		SetChangedFunc(func() {
+			u.app.Draw()
+		})
+	resultView.SetBackgroundColor(tcell.ColorDefault)
+	resultView.SetTextColor(tcell.NewRGBColor(191, 191, 191))
+
+	// 搜索框
+	searchView := tview.NewInputField()
+	searchView.SetPlaceholder(" 这里输入要搜索的内容")
+
+	style := tcell.StyleDefault
+	style = style.Background(tcell.NewRGBColor(43, 43, 43))
+	style = style.Foreground(tcell.NewRGBColor(191, 191, 191))
+	searchView.SetPlaceholderStyle(style)
+	searchView.SetFieldStyle(style)
+
+	searchView.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			resultView.SetText(u.core.Search(searchView.GetText())).ScrollToBeginning()
+		}
+		if key == tcell.KeyEscape {
+			searchView.SetText("")
+		}
+	})
+
+	// 布局
+	search := tview.NewGrid().
+		SetRows(1, 0)
+	search.SetBackgroundColor(tcell.ColorDefault)
+
+	search.AddItem(searchView, 0, 0, 1, 1, 0, 0, true)
+	search.AddItem(resultView, 1, 0, 1, 1, 0, 0, false)
+
+	u.pages.AddPage(pageSearch, search, true, false)
+}
+
 func (u *Simple) Stop() {
 	u.app.Stop()
 }
@@ -260,57 +326,19 @@ func (u *Simple) handleMsg(msg *core.WsMsgReply) {
 					action = fmt.Sprintf(`[#ff0000]["%s"]石头[""] ["%s"]剪刀[""] ["%s"]布[""] ["%s"]随机[""]`, uid1, uid2, uid3, rand)
 				} else {
 					uid := u.addMessageRecord(msg, actionRedPacket)
-					action = fmt.Sprintf(fmt.Sprintf(`[#ff0000]["%s"]打开[""]`, uid))
+					action = fmt.Sprintf(`[#ff0000]["%s"]打开[""]`, uid)
 				}
 				message = fmt.Sprintf("[#bfbfbf]%s [#bbbbbb]%s[#bfbfbf](%s)[#bbbbbb]: %s[#ff0000]%s%s [#bbbbbb]里面有[#ff0000]%d[#bbbbbb]积分(%d/%d) %s", msg.Time[11:], msg.UserNickname, msg.UserName, rp.Msg, rp.TypeName(), special, rp.Money, rp.Got, rp.Count, action)
 			} else {
 				message = msg.Msg()
 			}
-		} else if strings.Contains(msg.Content, "https://www.lingmx.com/card/index2.html") {
-			//message = msg.decodeWeatherMsg()
-		} else if strings.Contains(msg.Content, "https://www.lingmx.com/card/index.html") {
-			//message = msg.decodeSingleWeatherMsg()
 		} else {
-			content := func() string {
-				if msg.Md != "" {
-					// todo 终端解析markdown
-					return msg.Md
-				}
-				return msg.Content
-			}()
-
-			content = func(msg string) string {
-				strs := strings.Split(msg, "\n")
-
-				var ss []string
-				for _, str := range strs {
-					if strings.HasPrefix(str, ">") ||
-						strings.HasPrefix(str, "##### 引用") ||
-						strings.TrimSpace(str) == "" ||
-						strings.Contains(str, "https://zsh4869.github.io/fishpi.io/?hyd=") ||
-						strings.Contains(str, "extension-message") ||
-						strings.Contains(str, ":sweat_drops:") ||
-						strings.Contains(str, "下次更新时间") ||
-						strings.Contains(str, "https://unv-shield.librian.net/api/unv_shield") ||
-						strings.Contains(str, "EXP") ||
-						strings.Contains(str, "<span class='IceNet-") ||
-						(strings.Contains(str, "<!--") && strings.Contains(str, "-->")) ||
-						strings.Contains(str, "今天的活跃度是") {
-						continue
-					}
-					// 去除<span id = 'elves'></span>
-					pattern := `<span\s+[^>]*id\s*=\s*['"]([^'"]+)['"][^>]*>(.*?)</span>`
-					reg := regexp.MustCompile(pattern)
-					str = reg.ReplaceAllString(str, "")
-					if str == "" {
-						continue
-					}
+			// todo 终端解析markdown
+			content := msg.Body()
 
-					ss = append(ss, str)
-				}
-
-				return strings.Join(ss, "\n")
-			}(content)
+			if msg.Quote != nil {
+				content = fmt.Sprintf("%s [#888888]%s", content, tview.Escape(msg.Quote.Summary()))
+			}
 
 			uid := u.addMessageRecord(msg, actionMenu)
 			message = fmt.Sprintf(`[#bfbfbf]%s [#bbbbbb]%s[#bfbfbf]["%s"](%s)[""][#bbbbbb]: %s[#bfbfbf](%s)`, msg.Time[11:], msg.UserNickname, uid, msg.UserName, content, msg.Client)
@@ -339,6 +367,15 @@ func (u *Simple) showInfo(info string) {
 	}
 }
 
+// Print 指令的结果展示在信息框
+func (u *Simple) Print(msg string) {
+	u.showInfo(msg)
+}
+
+func (u *Simple) Printf(format string, a ...interface{}) {
+	u.showInfo(fmt.Sprintf(format, a...))
+}
+
 func (u *Simple) addMessageRecord(msg *core.WsMsgReply, action string) string {
 	u.indexMu.Lock()
 	defer u.indexMu.Unlock()
@@ -355,6 +392,8 @@ func (u *Simple) addMessageRecord(msg *core.WsMsgReply, action string) string {
 
 const (
 	messageMenuRepeat = "复读机"
+	messageMenuReply  = "回复"
+	messageMenuRevoke = "撤回"
 	messageMenuBlock  = "屏蔽此人"
 	messageMenuInfo   = "查询信息"
 	messageMenuClose  = "关闭"
@@ -364,19 +403,37 @@ func (u *Simple) openMessageMenu(msg *core.WsMsgReply) {
 	if u.pages.HasPage(pageMessageMenu) {
 		u.pages.HidePage(pageMessageMenu).RemovePage(pageMessageMenu)
 	}
+	buttons := []string{messageMenuReply, messageMenuRepeat, messageMenuBlock, messageMenuInfo, messageMenuClose}
+	if u.core.IsOwn(msg) {
+		buttons = []string{messageMenuReply, messageMenuRepeat, messageMenuRevoke, messageMenuInfo, messageMenuClose}
+	}
 	u.pages.AddPage(
 		pageMessageMenu,
 		tview.NewModal().
 			SetText(msg.Msg()).
 			SetBackgroundColor(tcell.ColorDefault).
-			AddButtons([]string{messageMenuRepeat, messageMenuBlock, messageMenuInfo, messageMenuClose}).
+			AddButtons(buttons).
 			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-				if buttonLabel == messageMenuRepeat {
+				if buttonLabel == messageMenuReply {
+					// 在输入框中补全回复指令 输入内容后回车发送
+					u.inputView.SetText(fmt.Sprintf("/reply %s ", msg.OId))
+					u.app.SetFocus(u.inputView)
+				} else if buttonLabel == messageMenuRepeat {
 					if err := u.core.SendPublicMsg(msg.Md); err != nil {
 						u.showInfo(fmt.Sprintf("send %s error: %s", msg.Md, err))
 					}
+				} else if buttonLabel == messageMenuRevoke {
+					if err := u.core.Revoke(msg); err != nil {
+						u.showInfo(fmt.Sprintf("撤回消息失败 %s", err))
+					} else {
+						u.showInfo("撤回消息操作成功")
+					}
 				} else if buttonLabel == messageMenuBlock {
-					// todo 屏蔽功能
+					if r, err := u.core.BlockUser(msg.UserName); err != nil {
+						u.showInfo(fmt.Sprintf("屏蔽%s失败 %s", msg.UserName, err))
+					} else {
+						u.showInfo(fmt.Sprintf("已添加屏蔽规则 %s 使用 /unblock %d 取消", r, r.ID))
+					}
 				} else if buttonLabel == messageMenuInfo {
 					u.showInfo(u.core.GetUserInfo(msg.UserName))
 				} else if buttonLabel != messageMenuClose {
@@ -392,9 +449,9 @@ func (u *Simple) openMessageMenu(msg *core.WsMsgReply) {
 }
 
 func (u *Simple) openRedPacket(msg *core.WsMsgReply, gesture string) {
-	result, err := u.core.OpenRedPacket(msg.OId, gesture)
+	result, err := u.core.OpenRedPacket(msg, gesture)
 	if err != nil {
-		u.showInfo(fmt.Sprintf("open %s error: %s", msg.Msg(), result))
+		u.showInfo(fmt.Sprintf("open %s error: %s", msg.Msg(), err))
 		return
 	}
 	u.showInfo(result)
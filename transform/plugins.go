@@ -0,0 +1,177 @@
+package transform
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var kaibaiPattern = regexp.MustCompile(`<span class="kaibai">([a-zA-Z0-9]+)</span>`)
+
+// kaibai 把神秘代码替换为可以直接打开的链接
+type kaibai struct {
+	url   string
+	token string
+}
+
+func (k *kaibai) Name() string { return Kaibai }
+
+func (k *kaibai) Transform(m *Message) {
+	m.Text = kaibaiPattern.ReplaceAllStringFunc(m.Text, func(s string) string {
+		link := fmt.Sprintf("%s/%s", k.url, kaibaiPattern.FindStringSubmatch(s)[1])
+		if k.token != "" {
+			link += "?token=" + url.QueryEscape(k.token)
+		}
+		return link
+	})
+}
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// trackingParams 需要去掉的跟踪参数 utm_开头的参数全部去掉
+var trackingParams = map[string]bool{
+	"spm":          true,
+	"from_spmid":   true,
+	"share_source": true,
+	"share_medium": true,
+	"share_from":   true,
+	"vd_source":    true,
+}
+
+// links 去掉链接中的跟踪参数
+type links struct{}
+
+func (links) Name() string { return Links }
+
+func (links) Transform(m *Message) {
+	m.Text = linkPattern.ReplaceAllStringFunc(m.Text, cleanLink)
+}
+
+func cleanLink(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.RawQuery == "" {
+		return link
+	}
+	query := u.Query()
+	removed := false
+	for key := range query {
+		if trackingParams[key] || strings.HasPrefix(key, "utm_") {
+			query.Del(key)
+			removed = true
+		}
+	}
+	if !removed {
+		return link
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// tailLines 包含这些内容的行是小尾巴
+var tailLines = []string{
+	"https://zsh4869.github.io/fishpi.io/?hyd=",
+	"extension-message",
+	":sweat_drops:",
+	"下次更新时间",
+	"https://unv-shield.librian.net/api/unv_shield",
+	"EXP",
+	"<span class='IceNet-",
+	"今天的活跃度是",
+}
+
+// elvesPattern 小飞棍等客户端附加的带id的span
+var elvesPattern = regexp.MustCompile(`<span\s+[^>]*id\s*=\s*['"]([^'"]+)['"][^>]*>(.*?)</span>`)
+
+// tails 去掉各种客户端附加的小尾巴
+type tails struct{}
+
+func (tails) Name() string { return Tails }
+
+func (tails) Transform(m *Message) {
+	lines := strings.Split(m.Text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if isTail(line) {
+			continue
+		}
+		if line = elvesPattern.ReplaceAllString(line, ""); line == "" {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	m.Text = strings.Join(kept, "\n")
+}
+
+func isTail(line string) bool {
+	if strings.Contains(line, "<!--") && strings.Contains(line, "-->") {
+		return true
+	}
+	for _, tail := range tailLines {
+		if strings.Contains(line, tail) {
+			return true
+		}
+	}
+	return false
+}
+
+var shortcodePattern = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+
+// shortcodes 常用的表情短代码
+var shortcodes = map[string]string{
+	"smile":         "😄",
+	"smiley":        "😃",
+	"grin":          "😁",
+	"joy":           "😂",
+	"laughing":      "😆",
+	"wink":          "😉",
+	"blush":         "😊",
+	"heart_eyes":    "😍",
+	"kissing_heart": "😘",
+	"sob":           "😭",
+	"cry":           "😢",
+	"sweat":         "😓",
+	"sweat_smile":   "😅",
+	"sweat_drops":   "💦",
+	"thinking":      "🤔",
+	"rage":          "😡",
+	"scream":        "😱",
+	"sleeping":      "😴",
+	"innocent":      "😇",
+	"sunglasses":    "😎",
+	"doge":          "🐶",
+	"fish":          "🐟",
+	"tropical_fish": "🐠",
+	"heart":         "❤️",
+	"broken_heart":  "💔",
+	"+1":            "👍",
+	"thumbsup":      "👍",
+	"-1":            "👎",
+	"thumbsdown":    "👎",
+	"ok_hand":       "👌",
+	"clap":          "👏",
+	"pray":          "🙏",
+	"muscle":        "💪",
+	"fire":          "🔥",
+	"tada":          "🎉",
+	"100":           "💯",
+	"coffee":        "☕",
+	"beer":          "🍺",
+	"watermelon":    "🍉",
+	"moneybag":      "💰",
+	"red_envelope":  "🧧",
+}
+
+// emoji 把表情短代码转换为emoji 不认识的短代码保持原样
+type emoji struct{}
+
+func (emoji) Name() string { return Emoji }
+
+func (emoji) Transform(m *Message) {
+	m.Text = shortcodePattern.ReplaceAllStringFunc(m.Text, func(s string) string {
+		if e, ok := shortcodes[strings.Trim(s, ":")]; ok {
+			return e
+		}
+		return s
+	})
+}
@@ -0,0 +1,120 @@
+package transform
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// 内置的插件 按此顺序执行
+const (
+	Kaibai  = "kaibai"  // 神秘代码解码为链接
+	Weather = "weather" // 天气卡片转换为文字
+	Links   = "links"   // 去掉链接中的跟踪参数
+	Tails   = "tails"   // 去掉各种客户端附加的小尾巴
+	Emoji   = "emoji"   // 表情短代码转换为emoji
+)
+
+// Names 内置的插件
+var Names = []string{Kaibai, Weather, Links, Tails, Emoji}
+
+// DefaultKaibaiUrl 神秘代码默认的解码地址
+const DefaultKaibaiUrl = "https://sexy.1433.top"
+
+// Options 插件配置 由配置文件转换而来 修改后通过Update生效
+type Options struct {
+	Disable   []string // 关闭的插件
+	KaibaiUrl string   // 神秘代码的解码地址 为空时使用 DefaultKaibaiUrl
+	Token     string   // 解码神秘代码使用的小飞棍令牌
+}
+
+// Message 插件改写的消息内容
+type Message struct {
+	Text string // 用于展示的Markdown 插件直接修改
+	Html string // 消息的HTML内容 只读 部分卡片只能从HTML中解析
+}
+
+// Plugin 消息内容改写插件
+type Plugin interface {
+	Name() string
+	Transform(m *Message)
+}
+
+// Pipeline 按顺序执行的插件 所有前端共用 并发安全
+type Pipeline struct {
+	mu       sync.RWMutex
+	builtin  []Plugin
+	extra    []Plugin
+	disabled map[string]bool
+}
+
+// New 创建包含全部内置插件的流水线
+func New(opts Options) (*Pipeline, error) {
+	p := new(Pipeline)
+	if err := p.Update(opts); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate 检查关闭的插件名称
+func Validate(disable []string) error {
+	for _, name := range disable {
+		if !slices.Contains(Names, name) {
+			return fmt.Errorf("插件应当是 %s：%s", strings.Join(Names, "/"), name)
+		}
+	}
+	return nil
+}
+
+// Update 更新插件配置 配置错误时保持之前的配置
+func (p *Pipeline) Update(opts Options) error {
+	if err := Validate(opts.Disable); err != nil {
+		return err
+	}
+	if opts.KaibaiUrl == "" {
+		opts.KaibaiUrl = DefaultKaibaiUrl
+	}
+	disabled := make(map[string]bool, len(opts.Disable))
+	for _, name := range opts.Disable {
+		disabled[name] = true
+	}
+
+	builtin := []Plugin{
+		&kaibai{url: strings.TrimSuffix(opts.KaibaiUrl, "/"), token: opts.Token},
+		weather{},
+		links{},
+		tails{},
+		emoji{},
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.builtin, p.disabled = builtin, disabled
+	return nil
+}
+
+// Register 在内置插件之后追加插件
+func (p *Pipeline) Register(plugins ...Plugin) *Pipeline {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.extra = append(p.extra, plugins...)
+	return p
+}
+
+// Apply 依次执行没有关闭的插件 p为nil时不做修改
+func (p *Pipeline) Apply(m *Message) {
+	if p == nil {
+		return
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, plugins := range [][]Plugin{p.builtin, p.extra} {
+		for _, plugin := range plugins {
+			if !p.disabled[plugin.Name()] {
+				plugin.Transform(m)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	p, err := New(Options{Token: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Message{Text: strings.Join([]string{
+		`看这个 <span class="kaibai">x7Yz</span> :joy:`,
+		`https://b23.tv/video?p=2&spm=1.2&utm_source=qq`,
+		`<span id='elves'>小飞棍来咯</span>`,
+		`:sweat_drops: 今天又摸了两小时鱼`,
+		`:unknown:`,
+	}, "\n")}
+	p.Apply(m)
+	want := strings.Join([]string{
+		`看这个 https://sexy.1433.top/x7Yz?token=abc 😂`,
+		`https://b23.tv/video?p=2`,
+		`:unknown:`,
+	}, "\n")
+	if m.Text != want {
+		t.Fatalf("Apply\n%q\nwant\n%q", m.Text, want)
+	}
+
+	// 关闭的插件不执行 配置错误时保持之前的配置
+	if err = p.Update(Options{Disable: []string{Emoji, Kaibai}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Update(Options{Disable: []string{"markdown"}}); err == nil {
+		t.Error("未知的插件应当报错")
+	}
+	m = &Message{Text: `<span class="kaibai">x7Yz</span> :joy:`}
+	p.Apply(m)
+	if m.Text != `<span class="kaibai">x7Yz</span> :joy:` {
+		t.Errorf("disabled plugins applied: %q", m.Text)
+	}
+
+	m = &Message{Text: `<iframe src="https://www.lingmx.com/card/index.html?m=5&d=1&w=晴&a=25" width="380"></iframe>`}
+	p.Apply(m)
+	if m.Text != "5月1日, 天气: 晴, 当前温度: 25 ℃" {
+		t.Errorf("weather card: %q", m.Text)
+	}
+}
@@ -0,0 +1,114 @@
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+const (
+	weatherCard         = "https://www.lingmx.com/card/index.html"  // 当天天气卡片
+	weatherForecastCard = "https://www.lingmx.com/card/index2.html" // 多日天气预报卡片
+)
+
+// weather 把天气卡片转换为文字
+type weather struct{}
+
+func (weather) Name() string { return Weather }
+
+func (weather) Transform(m *Message) {
+	if strings.Contains(m.Html, weatherForecastCard) {
+		m.Text = decodeWeather(m.Html)
+	} else if strings.Contains(m.Html, weatherCard) || strings.Contains(m.Text, weatherCard) {
+		m.Text = decodeSingleWeather(m.Text)
+	}
+}
+
+var (
+	badgePattern  = regexp.MustCompile(`<img src="https:\/\/img\.shields\.io\/badge\/.+">`)
+	iframePattern = regexp.MustCompile(`<iframe.+iframe>`)
+)
+
+func decodeSingleWeather(source string) string {
+	codeSource := badgePattern.FindString(source)
+	code := strings.TrimSuffix(strings.TrimPrefix(codeSource, `<img src="https://img.shields.io/badge/`), `">`)
+
+	linkSource := iframePattern.FindString(source)
+
+	var link string
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(linkSource))
+	if err != nil {
+		return source
+	}
+	dom.Find("iframe").Each(func(i int, s *goquery.Selection) {
+		link, _ = s.Attr("src")
+	})
+
+	u, e := url.Parse(link)
+	if e != nil {
+		return source
+	}
+	month := u.Query().Get("m")
+	day := u.Query().Get("d")
+	wea := u.Query().Get("w")
+	a := u.Query().Get("a")
+	weather := fmt.Sprintf("%s月%s日, 天气: %s, 当前温度: %s ℃", month, day, wea, a)
+	return strings.ReplaceAll(strings.ReplaceAll(source, codeSource, code), linkSource, weather)
+}
+
+func decodeWeather(html string) string {
+	//str := `<iframe src="https://www.lingmx.com/card/index2.html?date=8/19,8/20,8/21,8/22,8/23&weatherCode=LIGHT_RAIN,LIGHT_RAIN,CLOUDY,CLOUDY,LIGHT_RAIN&max=32,33,35,36,36&min=26,26,26,27,27&t=厦门&st=31分钟后开始下小雨，但56分钟后会停" width="380" height="370" frameborder="0"></iframe>`
+	msg := html
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(msg))
+	if err != nil {
+		return fmt.Sprintf("parse %s error: %s", html, err)
+	}
+	dom.Find(`iframe`).Each(func(i int, s *goquery.Selection) {
+		src, exist := s.Attr("src")
+		if !exist {
+			return
+		}
+		u, e := url.Parse(src)
+		if e != nil {
+			msg = fmt.Sprintf("parse %s error: %s", src, e)
+			return
+		}
+		msg = u.Query().Get("t") + "天气" + "\n"
+		data := [][]string{
+			strings.Split(u.Query().Get("weatherCode"), ","),
+			strings.Split(u.Query().Get("max"), ","),
+			strings.Split(u.Query().Get("min"), ","),
+		}
+
+		buffer := bytes.NewBufferString(msg)
+		table := tablewriter.NewTable(buffer,
+			tablewriter.WithConfig(tablewriter.Config{
+				Header: tw.CellConfig{
+					Alignment: tw.CellAlignment{
+						Global: tw.AlignCenter,
+					},
+				},
+				Row: tw.CellConfig{
+					Alignment: tw.CellAlignment{
+						Global: tw.AlignCenter,
+					},
+				},
+			}),
+		)
+		table.Header(strings.Split(u.Query().Get("date"), ","))
+
+		for _, v := range data {
+			table.Append(v)
+		}
+		table.Render()
+		msg = string(buffer.Bytes())
+		msg += u.Query().Get("st")
+	})
+	return msg
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"fishpi/config"
+	"fishpi/logger"
+	"fishpi/notify"
+	"fishpi/redpacket"
+	"fishpi/transform"
+)
+
+// 配置中由各个功能解释的字段在这里校验 config不依赖具体的功能
+func init() {
+	config.RegisterValidator(validateFeatures)
+}
+
+func validateFeatures(c *config.Config) []string {
+	var problems []string
+	add := func(format string, a ...any) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if l := c.Log; l != nil && l.Level != "" {
+		if _, err := logger.ParseLevel(l.Level); err != nil {
+			add("log.level 应当是 debug/info/warn/error：%s", l.Level)
+		}
+	}
+
+	if n := c.Notify; n != nil {
+		for _, name := range n.Notifiers {
+			switch {
+			case !slices.Contains(notify.Names, name):
+				add("notify.notifiers 应当是 %s：%s", strings.Join(notify.Names, "/"), name)
+			case name == notify.Command && n.Command == "":
+				add("notify.command 使用command提醒时不能为空")
+			case name == notify.Webhook && n.Webhook == "":
+				add("notify.webhook 使用webhook提醒时不能为空")
+			}
+		}
+		if _, err := notify.ParseQuietHours(n.QuietHours); err != nil {
+			add("notify.quietHours %s", err)
+		}
+	}
+
+	if r := c.RedPacket; r != nil {
+		if _, err := redpacket.ParseRules(r.Rules); err != nil {
+			add("redPacket.rules %s", err)
+		}
+	}
+
+	if t := c.Transform; t != nil {
+		if err := transform.Validate(t.Disable); err != nil {
+			add("transform.disable %s", err)
+		}
+	}
+	return problems
+}
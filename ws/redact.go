@@ -0,0 +1,27 @@
+package ws
+
+import "net/url"
+
+// secretParams 连接地址中不能出现在日志和事件中的参数
+var secretParams = []string{"apiKey", "token"}
+
+// Redact 隐藏连接地址中的apiKey等参数 用于日志和推送的连接状态
+func Redact(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "(invalid url)"
+	}
+	query := u.Query()
+	redacted := false
+	for _, key := range secretParams {
+		if query.Has(key) {
+			query.Set(key, "***")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return addr
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,16 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	got := Redact("wss://fishpi.cn/chat-channel?apiKey=secret&toUser=alice")
+	if strings.Contains(got, "secret") || !strings.Contains(got, "toUser=alice") {
+		t.Errorf("Redact = %s", got)
+	}
+	if addr := "wss://fishpi.cn/chat-room-channel"; Redact(addr) != addr {
+		t.Errorf("Redact changed %s", addr)
+	}
+}
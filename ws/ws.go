@@ -3,8 +3,8 @@ package ws
 import (
 	"context"
 	"errors"
-	"fmt"
-	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,52 +15,79 @@ import (
 
 type ws struct {
 	addr              string
-	reconnectInterval int
-	breakReconnect    bool
+	safeAddr          string // 隐藏了apiKey的地址 用于日志和连接状态
+	reconnectInterval atomic.Int64
 
-	client *websocket.Conn
+	mu             sync.Mutex // 保护client breakReconnect cancel 读写协程、重连协程和Stop会同时访问
+	breakReconnect bool
+	client         *websocket.Conn
+	cancel         context.CancelFunc
 
 	sendChan chan []byte
 	readChan chan []byte
+	done     chan struct{}
 
-	event  eventHandler.EventHandler
+	event  *eventHandler.Bus
 	logger logger.Logger
-
-	ctx    context.Context
-	cancel context.CancelFunc
 }
 
-func NewWs(addr string, reconnectInterval int, event eventHandler.EventHandler, logger logger.Logger) *ws {
+var errStopped = errors.New("连接已停止")
+
+func NewWs(addr string, reconnectInterval int, event *eventHandler.Bus, logger logger.Logger) *ws {
 	w := &ws{
-		addr:              addr,
-		reconnectInterval: reconnectInterval,
+		addr:     addr,
+		safeAddr: Redact(addr),
 
 		sendChan: make(chan []byte, 1024),
 		readChan: make(chan []byte, 1024),
+		done:     make(chan struct{}),
 
 		event:  event,
-		logger: logger,
+		logger: logger.Named("ws").With("addr", Redact(addr)),
 	}
 
+	w.reconnectInterval.Store(int64(reconnectInterval))
+
 	go w.handle()
 
 	return w
 }
 
+// SetReconnectInterval 修改断线重连的时间间隔 下一次重连时生效
+func (w *ws) SetReconnectInterval(reconnectInterval int) {
+	w.reconnectInterval.Store(int64(reconnectInterval))
+}
+
 func (w *ws) Start() error {
+	w.mu.Lock()
 	if w.client != nil {
+		w.mu.Unlock()
 		return errors.New("旧连接尚未断开")
 	}
 	w.breakReconnect = true
+	w.mu.Unlock()
 	return w.conn()
 }
 
 func (w *ws) Stop() error {
-	if w.client == nil {
-		return errors.New("没有检测到连接")
+	w.mu.Lock()
+	select {
+	case <-w.done:
+	default:
+		close(w.done)
 	}
 	w.breakReconnect = false
-	return w.client.Close()
+	c := w.client
+	w.client = nil
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.mu.Unlock()
+
+	if c == nil {
+		return errors.New("没有检测到连接")
+	}
+	return c.Close()
 }
 
 func (w *ws) conn() error {
@@ -69,36 +96,56 @@ func (w *ws) conn() error {
 	if err != nil {
 		return err
 	}
-	w.event.Pub(eventHandler.WsConnected, fmt.Sprintf("Websocket Connect Success"))
 
-	w.client = c
-	w.client.SetPongHandler(func(appData string) error {
-		log.Printf("recevice pong, %s\n", appData)
+	// 拨号期间调用了Stop 丢弃新连接
+	w.mu.Lock()
+	select {
+	case <-w.done:
+		w.mu.Unlock()
+		_ = c.Close()
+		return errStopped
+	default:
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.client, w.cancel = c, cancel
+	w.mu.Unlock()
+
+	eventHandler.Publish(w.event, eventHandler.TopicWsStatus, eventHandler.ConnState{Status: eventHandler.ConnConnected, Addr: w.safeAddr})
+
+	c.SetPongHandler(func(appData string) error {
+		w.logger.Debug("receive pong", "data", appData)
 		return nil
 	})
 
-	w.client.SetCloseHandler(func(code int, text string) error {
-		log.Printf("addr: %s\ncode: %d\ntext: %s\n", w.addr, code, text)
-		w.event.Pub(eventHandler.WsClosed, fmt.Sprintf("Websocket closed: \ncode: %d\ntext: %s\naddr: %s", code, text, w.addr))
-		w.reconnect()
+	c.SetCloseHandler(func(code int, text string) error {
+		w.logger.Info("connection closed", "code", code, "text", text)
+		eventHandler.Publish(w.event, eventHandler.TopicWsStatus, eventHandler.ConnState{Status: eventHandler.ConnClosed, Addr: w.safeAddr, Code: code, Text: text})
+		w.reconnect(c)
 
 		return nil
 	})
 
-	w.ctx, w.cancel = context.WithCancel(context.Background())
-	go w.read()
-	go w.write()
+	go w.read(c)
+	go w.write(ctx, c)
 
 	return nil
 }
 
+// reConn 按间隔重连直到成功或者调用了Stop
 func (w *ws) reConn() {
-	time.Sleep(time.Duration(w.reconnectInterval) * time.Second)
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-time.After(time.Duration(w.reconnectInterval.Load()) * time.Second):
+		}
 
-	if err := w.conn(); err != nil {
-		w.logger.Logf("conn %s error: %s", w.addr, err)
-		w.event.Pub(eventHandler.WsReconnectedFail, fmt.Sprintf("Websocket Reconnected failed\nerror: %s\naddr: %s", err, w.addr))
-		go w.reConn()
+		err := w.conn()
+		if err == nil || errors.Is(err, errStopped) {
+			return
+		}
+		w.logger.Error("reconnect failed", "err", err)
+		eventHandler.Publish(w.event, eventHandler.TopicWsStatus, eventHandler.ConnState{Status: eventHandler.ConnReconnectFailed, Addr: w.safeAddr, Err: err})
 	}
 }
 
@@ -114,27 +161,27 @@ func (w *ws) Send(data interface{}) {
 	w.sendChan <- msg
 }
 
-func (w *ws) write() {
+func (w *ws) write(ctx context.Context, c *websocket.Conn) {
 	for {
 		select {
 		case msg := <-w.sendChan:
-			if err := w.client.WriteMessage(websocket.TextMessage, msg); err != nil {
-				log.Printf("%s write message error: %s", w.addr, err)
+			if err := c.WriteMessage(websocket.TextMessage, msg); err != nil {
+				w.logger.Error("write message failed", "err", err)
 				return
 			}
-		case <-w.ctx.Done():
-			log.Printf("stop write progress\n")
+		case <-ctx.Done():
+			w.logger.Debug("stop write progress")
 			return
 		}
 	}
 }
 
-func (w *ws) read() {
+func (w *ws) read(c *websocket.Conn) {
 	for {
-		_, message, err := w.client.ReadMessage()
+		_, message, err := c.ReadMessage()
 		if err != nil {
-			log.Printf("%s read message error: %s, stop read message", w.addr, err)
-			w.reconnect()
+			w.logger.Warn("read message failed, stop read message", "err", err)
+			w.reconnect(c)
 			return
 		}
 		w.readChan <- message
@@ -145,20 +192,30 @@ func (w *ws) handle() {
 	for {
 		select {
 		case msg := <-w.readChan:
-			//log.Printf("receive message: %s\n", string(msg))
-			w.event.Pub(eventHandler.WsMsg, msg)
+			//w.logger.Debug("receive message", "msg", string(msg))
+			eventHandler.Publish(w.event, eventHandler.TopicWsMsg, msg)
+		case <-w.done:
+			return
 		}
 	}
 }
 
-func (w *ws) reconnect() {
-	w.cancel()
-	if err := w.client.Close(); err != nil {
-		log.Printf("close ws connect error: %s\n", err)
+// reconnect 关闭断开的连接c 需要时重连 c已经被Stop或者另一次reconnect处理过时直接返回
+func (w *ws) reconnect(c *websocket.Conn) {
+	w.mu.Lock()
+	if w.client != c {
+		w.mu.Unlock()
+		return
 	}
 	w.client = nil
+	w.cancel()
+	retry := w.breakReconnect
+	w.mu.Unlock()
 
-	if w.breakReconnect {
+	if err := c.Close(); err != nil {
+		w.logger.Warn("close connection failed", "err", err)
+	}
+	if retry {
 		go w.reConn()
 	}
 }
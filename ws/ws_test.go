@@ -0,0 +1,65 @@
+package ws
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"fishpi/eventHandler"
+	"fishpi/logger"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopWhileReconnecting(t *testing.T) {
+	var accepted, attempts atomic.Int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := attempts.Add(1)
+		// 第一次连接建立后立即断开 之后拒绝连接 客户端会一直重连
+		if n > 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		accepted.Add(1)
+		_ = c.Close()
+	}))
+	defer srv.Close()
+
+	l, _ := logger.NewMemory(slog.LevelError)
+	w := NewWs("ws"+strings.TrimPrefix(srv.URL, "http"), 0, eventHandler.NewBus("test", l), l)
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return attempts.Load() > 3 })
+
+	_ = w.Stop()
+	time.Sleep(50 * time.Millisecond)
+	n := attempts.Load()
+	time.Sleep(100 * time.Millisecond)
+	if attempts.Load() != n {
+		t.Fatalf("still reconnecting after Stop: %d -> %d", n, attempts.Load())
+	}
+	if accepted.Load() != 1 {
+		t.Fatalf("accepted %d", accepted.Load())
+	}
+}